@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ==================== Agents ====================
+
+// AgentProfile is one agent identity. The top-level `agent` block is used
+// when no `agents` list is configured; otherwise each list entry runs as
+// its own Bot. Empty fields fall back to the shared settings.
+type AgentProfile struct {
+	Name        string   `yaml:"name"`
+	PostID      int      `yaml:"post_id"`
+	AgentID     int      `yaml:"agent_id"`
+	ProjectID   int      `yaml:"project_id"`
+	APIKeyEnv   string   `yaml:"api_key_env"`  // env var holding this agent's Colosseum key
+	PromptsFile string   `yaml:"prompts_file"` // relative to the config directory
+	Keywords    []string `yaml:"keywords"`     // replaces the top-level keywords
+	StateFile   string   `yaml:"state_file"`
+	LogFile     string   `yaml:"log_file"`
+}
+
+// AgentSetup is everything a Bot needs to run one agent, with no reference
+// to process-wide state.
+type AgentSetup struct {
+	Config  Config // specialised for this agent (see forAgent)
+	Prompts Prompts
+	Summary SummaryTemplates
+	APIKey  string
+}
+
+// forAgent returns a copy of c for profile p. With several agents, shared
+// output files (log, tweets, summary, rate-limit counts, database) get the agent name
+// inserted so the bots never write to the same file.
+func (c Config) forAgent(p AgentProfile, multi bool) Config {
+	ac := c
+	ac.Agents = nil
+	if p.APIKeyEnv == "" {
+		p.APIKeyEnv = "COLOSSEUM_API_KEY"
+	}
+	if p.PromptsFile == "" {
+		p.PromptsFile = "prompts.yaml"
+	}
+	if len(p.Keywords) > 0 {
+		ac.Keywords = p.Keywords
+	}
+	if p.LogFile != "" {
+		ac.Output.LogFile = p.LogFile
+	} else if multi {
+		ac.Output.LogFile = agentFile(ac.Output.LogFile, p.Name)
+	}
+	if p.StateFile == "" {
+		p.StateFile = "nanopost_state.json"
+		if multi {
+			p.StateFile = agentFile(p.StateFile, p.Name)
+		}
+	}
+	if multi {
+		ac.Output.TweetPattern = agentFile(ac.Output.TweetPattern, p.Name)
+		ac.Output.SummaryPattern = agentFile(ac.Output.SummaryPattern, p.Name)
+		ac.Output.DryRunPattern = agentFile(ac.Output.DryRunPattern, p.Name)
+		ac.RateLimits.StateFile = agentFile(ac.RateLimits.StateFile, p.Name)
+		ac.State.SQLiteFile = agentFile(ac.State.SQLiteFile, p.Name)
+	}
+	ac.Agent = p
+	return ac
+}
+
+// agentFile inserts the agent name before the extension:
+// nanopost_state.json -> nanopost_state_moltpost-agent.json.
+func agentFile(path, name string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_" + name + ext
+}
+
+// loadAgents reads the layered config, the summary templates and each
+// agent's prompts file.
+// The returned setups share nothing, so the bots can run concurrently.
+func loadAgents(dir string, overrides map[string]string) ([]AgentSetup, configSources, error) {
+	c, sources, err := readConfig(filepath.Join(dir, "config.yaml"), overrides)
+	if err != nil {
+		return nil, sources, err
+	}
+	profiles := c.Agents
+	if len(profiles) == 0 {
+		profiles = []AgentProfile{c.Agent}
+	}
+	multi := len(profiles) > 1
+	summary := defaultSummaryTemplates()
+	if c.Summary.TemplateFile != "" {
+		if summary, err = readSummaryTemplates(filepath.Join(dir, c.Summary.TemplateFile)); err != nil {
+			return nil, sources, err
+		}
+	}
+	var setups []AgentSetup
+	for _, p := range profiles {
+		ac := c.forAgent(p, multi)
+		pr, err := readPrompts(filepath.Join(dir, ac.Agent.PromptsFile))
+		if os.IsNotExist(err) && ac.Agent.PromptsFile == "prompts.yaml" {
+			fmt.Printf("⚠️ prompts.yaml not found in %s, using defaults\n", dir)
+			pr, err = defaultPrompts(), nil
+		}
+		if err != nil {
+			return nil, sources, err
+		}
+		setups = append(setups, AgentSetup{Config: ac, Prompts: pr, Summary: summary, APIKey: os.Getenv(ac.Agent.APIKeyEnv)})
+	}
+	return setups, sources, nil
+}
+
+// runBots runs fn for every bot side by side and waits for all of them.
+func runBots(bots []*Bot, fn func(*Bot)) {
+	var wg sync.WaitGroup
+	for _, b := range bots {
+		wg.Add(1)
+		go func(b *Bot) {
+			defer wg.Done()
+			fn(b)
+		}(b)
+	}
+	wg.Wait()
+}
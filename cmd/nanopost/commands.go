@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== Commands ====================
+
+// actionCommand runs a single step of the heartbeat on demand. run returns
+// extra data for --json output, or nil when the round stats say it all.
+type actionCommand struct {
+	name, help string
+	run        func(ctx context.Context, b *Bot) interface{}
+}
+
+var actionCommands = []actionCommand{
+	{"reply", "reply to new comments on our posts", func(ctx context.Context, b *Bot) interface{} { b.CheckComments(ctx); return nil }},
+	{"discover", "vote for new posts matching the keywords", func(ctx context.Context, b *Bot) interface{} { b.DiscoverAndVote(ctx); return nil }},
+	{"vote-projects", "vote for other projects, agents we talked to first", func(ctx context.Context, b *Bot) interface{} { b.VoteProjects(ctx); return nil }},
+	{"engage", "comment on hot posts matching the keywords", func(ctx context.Context, b *Bot) interface{} { b.EngageWithPosts(ctx); return nil }},
+	{"mentions", "search the forum for mentions", func(ctx context.Context, b *Bot) interface{} { b.CheckMentions(ctx); return nil }},
+	{"leaderboard", "look up our leaderboard rank", func(ctx context.Context, b *Bot) interface{} { b.CheckLeaderboard(ctx); return nil }},
+	{"post-new", "create a new topic post if the interval has passed", func(ctx context.Context, b *Bot) interface{} { b.PostNew(ctx); return nil }},
+	{"post-progress", "post the daily progress update if 24h have passed", func(ctx context.Context, b *Bot) interface{} { b.PostProgress(ctx); return nil }},
+	{"status", "show agent status and project votes", func(ctx context.Context, b *Bot) interface{} { return b.ShowStatus(ctx) }},
+}
+
+func findActionCommand(name string) *actionCommand {
+	for i := range actionCommands {
+		if actionCommands[i].name == name {
+			return &actionCommands[i]
+		}
+	}
+	return nil
+}
+
+// commandFlags are the flags shared by every command, as opposed to the
+// --key=value config overrides.
+type commandFlags struct {
+	DryRun bool // record writes instead of sending them
+	Limit  int  // max items an action handles (replies, votes, comments, results); 0 = defaults
+	JSON   bool // print the result as JSON on stdout; logs go to the log file only
+}
+
+// parseCommandFlags pulls --dry-run, --limit N and --json out of args and
+// leaves everything else for parseConfigFlags.
+func parseCommandFlags(args []string) (rest []string, flags commandFlags, err error) {
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch name {
+		case "--dry-run":
+			flags.DryRun = true
+		case "--json":
+			flags.JSON = true
+		case "--limit":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, flags, fmt.Errorf("flag --limit needs a value")
+				}
+				i++
+				value = args[i]
+			}
+			if flags.Limit, err = strconv.Atoi(value); err != nil || flags.Limit < 0 {
+				return nil, flags, fmt.Errorf("flag --limit wants a number >= 0, got %q", value)
+			}
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, flags, nil
+}
+
+// limitReached reports whether --limit allows no more than n items.
+func (b *Bot) limitReached(n int) bool {
+	return b.limit > 0 && n >= b.limit
+}
+
+// fetchLimit returns --limit when set, otherwise the action's default.
+func (b *Bot) fetchLimit(n int) int {
+	if b.limit > 0 {
+		return b.limit
+	}
+	return n
+}
+
+// CommandResult is the --json output of one command for one agent.
+type CommandResult struct {
+	Command string          `json:"command"`
+	Agent   string          `json:"agent"`
+	DryRun  bool            `json:"dry_run"`
+	Stats   RoundStats      `json:"stats"`
+	Planned []PlannedAction `json:"planned,omitempty"`
+	Data    interface{}     `json:"data,omitempty"`
+}
+
+// RunAction runs one command outside the heartbeat and saves state (or the
+// dry-run report) the same way a heartbeat does, also when ctx is cancelled.
+func (b *Bot) RunAction(ctx context.Context, cmd *actionCommand) CommandResult {
+	b.resetRoundStats()
+	b.retryBudget.Reset(time.Duration(b.cfg.Retry.MaxHeartbeatSeconds) * time.Second)
+	b.action = cmd.name
+	data := cmd.run(ctx, b)
+	b.action, b.post = "", 0
+	if b.dryRun {
+		if len(b.planned) > 0 {
+			if err := b.saveDryRunReport(); err != nil {
+				b.logError("❌ Failed to save dry-run report: %v", err)
+			}
+		}
+	} else {
+		if err := b.saveState(); err != nil {
+			b.logError("❌ Failed to save state: %v", err)
+		}
+		b.flushRateLimits()
+	}
+	return CommandResult{
+		Command: cmd.name,
+		Agent:   b.cfg.Agent.Name,
+		DryRun:  b.dryRun,
+		Stats:   b.roundStats,
+		Planned: b.planned,
+		Data:    data,
+	}
+}
+
+// printResults writes the --json output: one object for a single agent,
+// an array when several agents ran.
+func printResults(results []CommandResult) {
+	var v interface{} = results
+	if len(results) == 1 {
+		v = results[0]
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func printUsage() {
+	fmt.Println(`Usage: nanopost [command] [--dry-run] [--limit N] [--json] [--key=value ...]
+
+Commands:
+  (none) | <minutes>   run the scheduler loop; <minutes> replaces bot.default_interval_minutes
+  once                 run one full heartbeat (every action, ignoring the schedule)
+  dry-run              run one heartbeat without sending anything (same as once --dry-run)
+  validate             check config.yaml and the prompts files
+  config print         show every effective config value and where it came from
+  schedule [minutes]   show the next planned runs of each action (--limit N runs)
+  history [rounds] [kind:K] [agent:NAME] [text]
+                       search replies, votes, posts and tweets (sqlite backend)`)
+	for _, c := range actionCommands {
+		fmt.Printf("  %-20s %s\n", c.name, c.help)
+	}
+	fmt.Println(`
+Flags:
+  --dry-run            record votes, comments and posts in a report instead of sending them
+  --limit N            handle at most N items (replies, votes, comments, search results)
+  --json               print the result as JSON; logs go to the log file only
+  --key=value          override a config key, e.g. --bot.max_engagements_per_cycle=3`)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ==================== Control API ====================
+//
+// An optional HTTP server for a running loop, bound to localhost unless
+// configured otherwise:
+//
+//	GET  /healthz    liveness, never needs the token
+//	GET  /status     last heartbeat, current round stats, next planned run
+//	GET  /state      how many comments, posts, projects and agents are handled
+//	GET  /metrics    Prometheus metrics (see metrics.go)
+//	POST /heartbeat  run a full heartbeat now
+//	POST /pause      skip write actions (replies, votes, comments, posts)
+//	POST /resume     allow them again
+//
+// With several agents every endpoint answers for all of them, or for one
+// with ?agent=name.
+
+// ControlConfig configures the control API.
+type ControlConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Listen   string `yaml:"listen"`    // host:port; keep on 127.0.0.1 unless a token is set
+	TokenEnv string `yaml:"token_env"` // env var holding the bearer token; empty = no auth
+}
+
+// writeActions are the heartbeat actions skipped while paused.
+var writeActions = map[string]bool{
+	"reply": true, "discover": true, "vote-projects": true, "engage": true,
+	"post-new": true, "post-progress": true,
+}
+
+// errPaused is returned by write calls while the bot is paused.
+var errPaused = errors.New("write actions are paused")
+
+// BotStatus is what /status reports for one agent.
+type BotStatus struct {
+	Agent         string     `json:"agent"`
+	Paused        bool       `json:"paused"`
+	Running       bool       `json:"running"` // a heartbeat is in progress
+	Due           []string   `json:"due,omitempty"`
+	RoundStarted  time.Time  `json:"round_started,omitempty"`
+	LastHeartbeat time.Time  `json:"last_heartbeat,omitempty"` // when the last heartbeat finished
+	Round         RoundStats `json:"round"`                    // current heartbeat, or the last one
+	NextRun       time.Time  `json:"next_run,omitempty"`
+	NextActions   []string   `json:"next_actions,omitempty"`
+}
+
+// StateInfo is what /state reports for one agent.
+type StateInfo struct {
+	Agent            string         `json:"agent"`
+	Backend          string         `json:"backend"`
+	Handled          map[string]int `json:"handled"` // comment, post, project, agent
+	LastProgressPost time.Time      `json:"last_progress_post"`
+	LastNewPost      time.Time      `json:"last_new_post"`
+}
+
+// publish updates the status seen by the control API.
+func (b *Bot) publish(update func(s *BotStatus)) {
+	b.statusMu.Lock()
+	defer b.statusMu.Unlock()
+	update(&b.status)
+}
+
+// publishState refreshes the /state counts. It runs on the bot's own
+// goroutine, between rounds, so it can read the in-memory sets.
+func (b *Bot) publishState() {
+	info := StateInfo{
+		Agent:            b.cfg.Agent.Name,
+		Backend:          b.cfg.State.Backend,
+		LastProgressPost: b.lastProgressPost,
+		LastNewPost:      b.lastNewPost,
+	}
+	if b.db != nil {
+		counts, err := b.db.Counts()
+		if err != nil {
+			b.logError("❌ Failed to count state: %v", err)
+		}
+		info.Handled = counts
+	} else {
+		info.Handled = map[string]int{
+			"comment":  len(b.seen.comments),
+			"post":     len(b.seen.posts),
+			"project":  len(b.seen.projects),
+			"agent":    len(b.seen.agents),
+			"own_post": len(b.seen.ownPosts),
+			"declined": len(b.seen.declined),
+			"reply":    len(b.seen.replies),
+		}
+	}
+	b.statusMu.Lock()
+	b.stateInfo = info
+	b.statusMu.Unlock()
+}
+
+func (b *Bot) snapshot() (BotStatus, StateInfo) {
+	b.statusMu.Lock()
+	defer b.statusMu.Unlock()
+	s := b.status
+	s.Agent = b.name
+	s.Paused = b.paused.Load()
+	return s, b.stateInfo
+}
+
+// controlServer serves the control API for a set of bots.
+type controlServer struct {
+	bots  []*Bot
+	token string
+}
+
+func newControlServer(bots []*Bot, token string) *controlServer {
+	return &controlServer{bots: bots, token: token}
+}
+
+func (cs *controlServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.Handle("/metrics", registry.Handler())
+	mux.HandleFunc("/status", cs.get(func(b *Bot) interface{} { s, _ := b.snapshot(); return s }))
+	mux.HandleFunc("/state", cs.get(func(b *Bot) interface{} { _, s := b.snapshot(); return s }))
+	mux.HandleFunc("/heartbeat", cs.post(func(b *Bot) interface{} {
+		select {
+		case b.trigger <- struct{}{}:
+			return map[string]string{"agent": b.name, "heartbeat": "queued"}
+		default:
+			return map[string]string{"agent": b.name, "heartbeat": "already queued"}
+		}
+	}))
+	mux.HandleFunc("/pause", cs.post(func(b *Bot) interface{} {
+		if !b.paused.Swap(true) {
+			b.notice("⏸️ Write actions paused via control API")
+		}
+		s, _ := b.snapshot()
+		return s
+	}))
+	mux.HandleFunc("/resume", cs.post(func(b *Bot) interface{} {
+		if b.paused.Swap(false) {
+			b.notice("▶️ Write actions resumed via control API")
+		}
+		s, _ := b.snapshot()
+		return s
+	}))
+	return cs.auth(mux)
+}
+
+// auth requires the bearer token on everything but /healthz.
+func (cs *controlServer) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cs.token != "" && r.URL.Path != "/healthz" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(cs.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cs *controlServer) get(fn func(*Bot) interface{}) http.HandlerFunc {
+	return cs.each(http.MethodGet, fn)
+}
+
+func (cs *controlServer) post(fn func(*Bot) interface{}) http.HandlerFunc {
+	return cs.each(http.MethodPost, fn)
+}
+
+// each runs fn for the bot named in ?agent= (or every bot) and writes the
+// results as JSON: one object for one bot, an array otherwise.
+func (cs *controlServer) each(method string, fn func(*Bot) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		bots := cs.bots
+		if name := r.URL.Query().Get("agent"); name != "" {
+			bots = nil
+			for _, b := range cs.bots {
+				if b.name == name {
+					bots = append(bots, b)
+				}
+			}
+			if len(bots) == 0 {
+				http.Error(w, fmt.Sprintf("unknown agent %q", name), http.StatusNotFound)
+				return
+			}
+		}
+		var results []interface{}
+		for _, b := range bots {
+			results = append(results, fn(b))
+		}
+		var v interface{} = results
+		if len(results) == 1 {
+			v = results[0]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+	}
+}
+
+// serve listens until ctx is cancelled. Listen errors are returned right
+// away so a taken port fails at startup.
+func (cs *controlServer) serve(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("control API: %w", err)
+	}
+	srv := &http.Server{Handler: cs.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("❌ control API stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// isLoopback reports whether addr only accepts local connections.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
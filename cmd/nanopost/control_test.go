@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func controlBot(name string) *Bot {
+	return &Bot{name: name, trigger: make(chan struct{}, 1), quiet: true}
+}
+
+// call sends method path to h with an optional bearer token.
+func call(h http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestControlAuth(t *testing.T) {
+	h := newControlServer([]*Bot{controlBot("alice")}, "s3cret").handler()
+	tests := []struct {
+		path, token string
+		want        int
+	}{
+		{"/status", "", http.StatusUnauthorized},
+		{"/status", "wrong", http.StatusUnauthorized},
+		{"/status", "s3cret", http.StatusOK},
+		{"/healthz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := call(h, http.MethodGet, tt.path, tt.token)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s with token %q = %d, want %d", tt.path, tt.token, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("401 without a WWW-Authenticate challenge")
+		}
+	}
+
+	open := newControlServer([]*Bot{controlBot("alice")}, "").handler()
+	if rec := call(open, http.MethodGet, "/status", ""); rec.Code != http.StatusOK {
+		t.Errorf("no token configured: GET /status = %d, want 200", rec.Code)
+	}
+}
+
+func TestControlPauseResume(t *testing.T) {
+	b := controlBot("alice")
+	h := newControlServer([]*Bot{b}, "").handler()
+
+	if rec := call(h, http.MethodGet, "/pause", ""); rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Errorf("GET /pause = %d (Allow %q), want 405 POST", rec.Code, rec.Header().Get("Allow"))
+	}
+	var s BotStatus
+	rec := call(h, http.MethodPost, "/pause", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil || !s.Paused || !b.paused.Load() {
+		t.Fatalf("POST /pause = %s (%v), want paused", rec.Body, err)
+	}
+	call(h, http.MethodPost, "/resume", "")
+	if b.paused.Load() {
+		t.Error("still paused after /resume")
+	}
+}
+
+func TestControlHeartbeatAndAgents(t *testing.T) {
+	alice, bob := controlBot("alice"), controlBot("bob")
+	h := newControlServer([]*Bot{alice, bob}, "").handler()
+
+	var got []map[string]string
+	json.Unmarshal(call(h, http.MethodPost, "/heartbeat", "").Body.Bytes(), &got)
+	if len(got) != 2 || got[0]["heartbeat"] != "queued" || got[1]["agent"] != "bob" {
+		t.Errorf("POST /heartbeat for all = %v", got)
+	}
+	var one map[string]string
+	json.Unmarshal(call(h, http.MethodPost, "/heartbeat?agent=bob", "").Body.Bytes(), &one)
+	if one["heartbeat"] != "already queued" {
+		t.Errorf("second heartbeat for bob = %v, want already queued", one)
+	}
+	if rec := call(h, http.MethodGet, "/status?agent=carol", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown agent = %d, want 404", rec.Code)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:80":   true,
+		"[::1]:9000":     true,
+		"0.0.0.0:8080":   false,
+		":8080":          false,
+		"10.0.0.5:8080":  false,
+		"127.0.0.1":      false, // no port
+	} {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
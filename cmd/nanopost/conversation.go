@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"nanopost/internal/colosseum"
+	"nanopost/internal/history"
+)
+
+// ==================== Conversation Context ====================
+//
+// A reply is generated from the conversation so far: our post, the comments
+// before the one being answered and our past exchanges with the commenter,
+// sent as chat turns (theirs as "user", ours as "assistant") ahead of the
+// reply prompt. The newest turns are kept when the token budget runs out.
+
+type ConversationConfig struct {
+	ContextTokens int    `yaml:"context_tokens"` // budget for earlier turns in a reply; 0 = the comment only
+	PastExchanges int    `yaml:"past_exchanges"` // earlier exchanges with the commenter on other posts (sqlite backend)
+	Threading     string `yaml:"threading"`      // parent | quote | off; see threading.go
+}
+
+// replyThread is what generateReply knows about the comment it answers.
+type replyThread struct {
+	post    *colosseum.Post     // the post commented on; nil if it could not be fetched
+	earlier []colosseum.Comment // comments before this one, oldest first
+}
+
+// threadFor returns the thread of comments[i]: the chain of comments it
+// answers if it has a parent, otherwise every comment before it. The API
+// lists comments newest first, so the earlier ones come after it.
+func threadFor(post *colosseum.Post, comments []colosseum.Comment, i int) replyThread {
+	t := replyThread{post: post}
+	if parent := comments[i].ParentID; parent != 0 {
+		byID := make(map[int]colosseum.Comment, len(comments))
+		for _, c := range comments {
+			byID[c.ID] = c
+		}
+		for parent != 0 && len(t.earlier) < len(comments) { // the length check stops a parent cycle
+			c, ok := byID[parent]
+			if !ok {
+				break
+			}
+			t.earlier = append([]colosseum.Comment{c}, t.earlier...)
+			parent = c.ParentID
+		}
+		return t
+	}
+	for j := len(comments) - 1; j > i; j-- {
+		t.earlier = append(t.earlier, comments[j])
+	}
+	return t
+}
+
+// postContext fills {{.PostContext}} in the reply prompt.
+func (t replyThread) postContext() string {
+	if t.post == nil || (t.post.Title == "" && t.post.Body == "") {
+		return ""
+	}
+	return fmt.Sprintf("My post %q:\n%s", t.post.Title, truncate(t.post.Body, 1000))
+}
+
+// conversation returns the earlier turns for a reply to agent, newest
+// kept first, within the conversation.context_tokens budget.
+func (b *Bot) conversation(t replyThread, agent string) []ChatMessage {
+	budget := b.cfg.Conversation.ContextTokens
+	if budget <= 0 {
+		return nil
+	}
+	turns := b.pastExchanges(agent, t.post)
+	for _, c := range t.earlier {
+		if c.AgentName == b.cfg.Agent.Name {
+			turns = append(turns, ChatMessage{Role: "assistant", Content: c.Body})
+		} else {
+			turns = append(turns, ChatMessage{Role: "user", Content: fmt.Sprintf("@%s: %s", c.AgentName, c.Body)})
+		}
+	}
+	used, start := 0, len(turns)
+	for start > 0 {
+		n := estimateTokens(turns[start-1].Content)
+		if used+n > budget {
+			break
+		}
+		used += n
+		start--
+	}
+	if kept := len(turns) - start; kept > 0 {
+		b.logDebug("🧵 Reply context: %d of %d earlier turns (~%d tokens)", kept, len(turns), used)
+	}
+	return turns[start:]
+}
+
+// pastExchanges turns our last replies to and comments for agent on other
+// posts into chat turns, oldest first. Only the sqlite backend keeps them.
+func (b *Bot) pastExchanges(agent string, post *colosseum.Post) []ChatMessage {
+	n := b.cfg.Conversation.PastExchanges
+	if b.db == nil || n <= 0 {
+		return nil
+	}
+	entries, err := b.db.Search(history.Query{Agent: agent, Limit: 4 * n})
+	if err != nil {
+		b.logWarn("⚠️ Failed to read past exchanges with @%s: %v", agent, err)
+		return nil
+	}
+	var picked []history.Entry
+	for _, e := range entries { // newest first
+		if len(picked) == n {
+			break
+		}
+		if e.Sent == "" || (e.Kind != "reply" && e.Kind != "comment") || (post != nil && e.PostID == post.ID) {
+			continue // the current post's thread is sent as it is
+		}
+		picked = append(picked, e)
+	}
+	var turns []ChatMessage
+	for i := len(picked) - 1; i >= 0; i-- {
+		e := picked[i]
+		said := fmt.Sprintf("@%s on post #%d: %s", agent, e.PostID, e.Received)
+		if e.Kind == "comment" {
+			said = fmt.Sprintf("@%s's post #%d: %s", agent, e.PostID, e.Received)
+		}
+		turns = append(turns, ChatMessage{Role: "user", Content: said}, ChatMessage{Role: "assistant", Content: e.Sent})
+	}
+	return turns
+}
+
+// estimateTokens is a rough count (four ASCII characters per token, one
+// token per other character) that is good enough for a budget.
+func estimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return ascii/4 + other + 1
+}
+
+// chatTurns builds the messages for one call: the system prompt, the
+// earlier turns and the prompt. Consecutive turns of one role are merged
+// and the history starts with a user turn, as some providers require.
+func chatTurns(system string, earlier []ChatMessage, prompt string) []ChatMessage {
+	msgs := []ChatMessage{{Role: "system", Content: system}}
+	add := func(m ChatMessage) {
+		last := &msgs[len(msgs)-1]
+		if last.Role == m.Role {
+			last.Content += "\n\n" + m.Content
+			return
+		}
+		if len(msgs) == 1 && m.Role == "assistant" {
+			msgs = append(msgs, ChatMessage{Role: "user", Content: "(earlier in the conversation)"})
+		}
+		msgs = append(msgs, m)
+	}
+	for _, m := range earlier {
+		add(m)
+	}
+	add(ChatMessage{Role: "user", Content: prompt})
+	return msgs
+}
+
+// generateReply answers comment c in thread t, falling back to the
+// fallback_reply template when the model fails.
+func (b *Bot) generateReply(ctx context.Context, t replyThread, c colosseum.Comment) string {
+	prompt := b.renderPrompt(b.prompts.Reply, map[string]string{"AgentName": c.AgentName, "CommentBody": c.Body, "PostContext": t.postContext()})
+	reply, err := b.llm.Chat(ctx, chatTurns(b.prompts.System, b.conversation(t, c.AgentName), strings.TrimSpace(prompt)))
+	if err != nil {
+		if ctx.Err() == nil {
+			b.logWarn("⚠️ AI error, using the fallback reply: %v", err)
+			metricFallbackReplies.Inc(b.cfg.Agent.Name)
+		}
+		return b.renderPrompt(b.prompts.FallbackReply, map[string]string{"AgentName": c.AgentName})
+	}
+	return reply
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"nanopost/internal/colosseum"
+)
+
+func TestThreadFor(t *testing.T) {
+	// As the API lists them: newest first.
+	comments := []colosseum.Comment{{ID: 4}, {ID: 3}, {ID: 2}, {ID: 1}}
+	ids := func(cs []colosseum.Comment) []int {
+		var out []int
+		for _, c := range cs {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+	post := &colosseum.Post{ID: 9}
+	if got := threadFor(post, comments, 1); !reflect.DeepEqual(ids(got.earlier), []int{1, 2}) || got.post != post {
+		t.Errorf("thread of #3 = %v, want [1 2] oldest first", ids(got.earlier))
+	}
+	if got := threadFor(nil, comments, 3); len(got.earlier) != 0 {
+		t.Errorf("the oldest comment has no earlier ones, got %v", ids(got.earlier))
+	}
+	if got := threadFor(nil, comments, 0); !reflect.DeepEqual(ids(got.earlier), []int{1, 2, 3}) {
+		t.Errorf("thread of the newest = %v", ids(got.earlier))
+	}
+}
+
+func TestChatTurns(t *testing.T) {
+	tests := []struct {
+		name    string
+		earlier []ChatMessage
+		want    []ChatMessage
+	}{
+		{
+			name: "no history",
+			want: []ChatMessage{{"system", "sys"}, {"user", "prompt"}},
+		},
+		{
+			name:    "alternating",
+			earlier: []ChatMessage{{"user", "hi"}, {"assistant", "hello"}},
+			want:    []ChatMessage{{"system", "sys"}, {"user", "hi"}, {"assistant", "hello"}, {"user", "prompt"}},
+		},
+		{
+			name:    "same-role turns are merged, including into the prompt",
+			earlier: []ChatMessage{{"user", "@a: one"}, {"user", "@b: two"}},
+			want:    []ChatMessage{{"system", "sys"}, {"user", "@a: one\n\n@b: two\n\nprompt"}},
+		},
+		{
+			name:    "history starting with our turn gets a user turn first",
+			earlier: []ChatMessage{{"assistant", "my post"}, {"assistant", "my reply"}, {"user", "@a: hmm"}},
+			want: []ChatMessage{
+				{"system", "sys"},
+				{"user", "(earlier in the conversation)"},
+				{"assistant", "my post\n\nmy reply"},
+				{"user", "@a: hmm\n\nprompt"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := chatTurns("sys", tt.earlier, "prompt"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConversationBudget(t *testing.T) {
+	b := &Bot{quiet: true}
+	b.cfg.Agent.Name = "me"
+	thread := replyThread{earlier: []colosseum.Comment{
+		{AgentName: "alice", Body: strings.Repeat("x", 400)}, // ~100 tokens
+		{AgentName: "me", Body: "short answer"},
+		{AgentName: "alice", Body: "and one more"},
+	}}
+
+	if got := b.conversation(thread, "alice"); got != nil {
+		t.Errorf("budget 0 = %v, want no context", got)
+	}
+	b.cfg.Conversation.ContextTokens = 50
+	got := b.conversation(thread, "alice")
+	want := []ChatMessage{{"assistant", "short answer"}, {"user", "@alice: and one more"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("budget 50 = %q, want the newest turns that fit: %q", got, want)
+	}
+	b.cfg.Conversation.ContextTokens = 1000
+	if got := b.conversation(thread, "alice"); len(got) != 3 || !strings.HasPrefix(got[0].Content, "@alice: xxx") {
+		t.Errorf("budget 1000 = %d turns", len(got))
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := estimateTokens(strings.Repeat("a", 40)); got != 11 {
+		t.Errorf("40 ASCII chars = %d tokens, want 11", got)
+	}
+	if got := estimateTokens("人机对话"); got != 5 {
+		t.Errorf("4 CJK chars = %d tokens, want 5", got)
+	}
+}
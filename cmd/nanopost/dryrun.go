@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ==================== Dry Run ====================
+
+// PlannedAction is a write the bot would have sent to Colosseum in a real run.
+type PlannedAction struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"` // vote | comment | post | vote-project | tweet
+	TargetID int       `json:"target_id,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Rule     string    `json:"rule"`
+}
+
+// because records why the next write action is happening, so the dry-run
+// report can show which rule triggered it.
+func (b *Bot) because(format string, args ...interface{}) {
+	b.rule = fmt.Sprintf(format, args...)
+}
+
+// plan records a write instead of sending it. It returns true when the bot
+// is in dry-run mode and the caller must not touch the API.
+func (b *Bot) plan(action string, targetID int, text string) bool {
+	if !b.dryRun {
+		return false
+	}
+	b.planned = append(b.planned, PlannedAction{
+		Time:     time.Now(),
+		Action:   action,
+		TargetID: targetID,
+		Text:     text,
+		Rule:     b.rule,
+	})
+	b.log("🧪 [dry-run] would %s #%d (%s)", action, targetID, b.rule)
+	return true
+}
+
+func (b *Bot) saveDryRunReport() error {
+	pattern := b.cfg.Output.DryRunPattern
+	if pattern == "" {
+		pattern = "dryrun_%s.md"
+	}
+	name := b.cfg.outputPath(fmt.Sprintf(pattern, time.Now().Format("2006-01-02_150405")))
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Nanopost Dry Run - %s\n\n", time.Now().Format("2006-01-02 15:04:05")))
+	sb.WriteString(fmt.Sprintf("Agent: @%s | Post: #%d | AI: %s/%s\n\n", b.cfg.Agent.Name, b.cfg.Agent.PostID, b.llm.Name(), b.llm.Model()))
+	sb.WriteString(fmt.Sprintf("Planned actions: %d\n", len(b.planned)))
+	for i, a := range b.planned {
+		sb.WriteString(fmt.Sprintf("\n---\n\n## %d. %s", i+1, a.Action))
+		if a.TargetID != 0 {
+			sb.WriteString(fmt.Sprintf(" #%d", a.TargetID))
+		}
+		sb.WriteString(fmt.Sprintf(" (%s)\n\n", a.Time.Format("15:04:05")))
+		sb.WriteString(fmt.Sprintf("- **Rule:** %s\n", a.Rule))
+		if a.Text != "" {
+			sb.WriteString("\n" + a.Text + "\n")
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	b.log("🧪 Dry-run report saved: %s (%d actions)", name, len(b.planned))
+	return nil
+}
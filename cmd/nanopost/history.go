@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"nanopost/internal/history"
+)
+
+// ==================== History Command ====================
+
+// HistoryResult is the --json output of `nanopost history` for one agent.
+type HistoryResult struct {
+	Agent   string          `json:"agent"`
+	Entries []history.Entry `json:"entries,omitempty"`
+	Rounds  []history.Round `json:"rounds,omitempty"`
+}
+
+// parseHistoryQuery reads `kind:reply agent:name some text` into a query.
+func parseHistoryQuery(args []string, limit int) history.Query {
+	q := history.Query{Limit: limit}
+	var text []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "kind:"):
+			q.Kind = strings.TrimPrefix(arg, "kind:")
+		case strings.HasPrefix(arg, "agent:"):
+			q.Agent = strings.TrimPrefix(strings.TrimPrefix(arg, "agent:"), "@")
+		default:
+			text = append(text, arg)
+		}
+	}
+	q.Text = strings.Join(text, " ")
+	return q
+}
+
+// runHistory implements `nanopost history`: it searches the interactions
+// (or, with `rounds`, lists the round stats) of every agent's database.
+func runHistory(setups []AgentSetup, args []string, flags commandFlags) error {
+	showRounds := len(args) > 0 && args[0] == "rounds"
+	if showRounds {
+		args = args[1:]
+	}
+	q := parseHistoryQuery(args, flags.Limit)
+	var results []HistoryResult
+	for _, setup := range setups {
+		c := setup.Config
+		if c.State.Backend != "sqlite" {
+			return fmt.Errorf("agent %s: history needs state.backend: sqlite (got %q)", c.Agent.Name, c.State.Backend)
+		}
+		if _, err := os.Stat(c.State.SQLiteFile); err != nil {
+			return fmt.Errorf("agent %s: %w", c.Agent.Name, err)
+		}
+		db, err := history.Open(c.State.SQLiteFile)
+		if err != nil {
+			return err
+		}
+		r := HistoryResult{Agent: c.Agent.Name}
+		if showRounds {
+			r.Rounds, err = db.Rounds(flags.Limit)
+		} else {
+			r.Entries, err = db.Search(q)
+		}
+		db.Close()
+		if err != nil {
+			return err
+		}
+		results = append(results, r)
+	}
+
+	if flags.JSON {
+		var v interface{} = results
+		if len(results) == 1 {
+			v = results[0]
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	for _, r := range results {
+		if len(results) > 1 {
+			fmt.Printf("== @%s ==\n", r.Agent)
+		}
+		for _, round := range r.Rounds {
+			fmt.Printf("%s  replies %d | votes %d | project votes %d | engagements %d | mentions %d | rank %d\n",
+				round.Time.Format("2006-01-02 15:04:05"), round.Replies, round.Votes, round.ProjectVotes,
+				round.Engagements, round.Mentions, round.LeaderboardRank)
+		}
+		for _, e := range r.Entries {
+			fmt.Println(formatHistoryEntry(e))
+		}
+		if len(r.Rounds) == 0 && len(r.Entries) == 0 {
+			fmt.Println("(nothing found)")
+		}
+	}
+	return nil
+}
+
+func formatHistoryEntry(e history.Entry) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s  %-12s", e.Time.Format("2006-01-02 15:04:05"), e.Kind))
+	if e.Agent != "" {
+		sb.WriteString(" @" + e.Agent)
+	}
+	if e.PostID != 0 {
+		sb.WriteString(fmt.Sprintf(" post #%d", e.PostID))
+	}
+	if e.TargetID != 0 {
+		sb.WriteString(fmt.Sprintf(" #%d", e.TargetID))
+	}
+	if e.CommentID != 0 {
+		sb.WriteString(fmt.Sprintf(" → #%d", e.CommentID))
+	}
+	if e.Detail != "" {
+		sb.WriteString(" (" + e.Detail + ")")
+	}
+	if e.Received != "" {
+		sb.WriteString("\n    < " + truncate(oneLine(e.Received), 160))
+	}
+	if e.Sent != "" {
+		sb.WriteString("\n    > " + truncate(oneLine(e.Sent), 160))
+	}
+	return sb.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
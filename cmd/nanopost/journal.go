@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// ==================== Journal ====================
+//
+// The state file is written once per heartbeat. In between, every side
+// effect is appended to a write-ahead journal (<state_file>.journal) as
+// soon as it succeeds, so a kill halfway through a round does not make the
+// bot answer the same comments again. On startup the journal is replayed
+// on top of the state file; after each successful saveState it is emptied.
+
+// journalEntry is one remembered side effect.
+type journalEntry struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"` // comment | post | project | agent | own_post | declined | reply | progress | new_post
+	ID   int       `json:"id,omitempty"`
+	Name string    `json:"name,omitempty"`
+	Ref  int       `json:"ref,omitempty"` // reply: our comment answering comment ID
+}
+
+func (b *Bot) journalFile() string { return b.stateFile + ".journal" }
+
+// apply updates the in-memory state from e. It is used both live and
+// during replay, so the two can never disagree.
+func (b *Bot) apply(e journalEntry) {
+	switch e.Kind {
+	case "progress":
+		b.lastProgressPost = e.Time
+	case "new_post":
+		b.lastNewPost = e.Time
+		b.topicIndex = e.ID
+	case "reply":
+		b.seen.replies[e.ID] = e.Ref
+	default:
+		b.seen.add(e.Kind, e.ID, e.Name)
+	}
+}
+
+// remember applies a side effect and appends it to the journal, or with
+// the SQLite backend writes it straight to the database. Dry runs only
+// update memory.
+func (b *Bot) remember(kind string, id int, name string) {
+	b.rememberEntry(journalEntry{Kind: kind, ID: id, Name: name})
+}
+
+func (b *Bot) rememberEntry(e journalEntry) {
+	e.Time = time.Now()
+	if b.db != nil && !b.dryRun {
+		if err := b.storeEntry(e); err != nil {
+			b.logError("❌ Failed to write state: %v", err)
+			b.apply(e) // at least don't repeat it in this process
+			return
+		}
+		if e.Kind == "progress" || e.Kind == "new_post" {
+			b.apply(e)
+		}
+		return
+	}
+	b.apply(e)
+	if b.dryRun {
+		return
+	}
+	if err := b.appendJournal(e); err != nil {
+		b.logError("❌ Failed to write journal: %v", err)
+	}
+}
+
+func (b *Bot) appendJournal(e journalEntry) error {
+	if b.journal == nil {
+		f, err := os.OpenFile(b.journalFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		b.journal = f
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := b.journal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return b.journal.Sync()
+}
+
+// replayJournal applies the entries written since the last saveState. A
+// torn last line (crash mid-append) is dropped; damage anywhere else is
+// reported but the readable entries are still applied.
+func (b *Bot) replayJournal() error {
+	data, err := os.ReadFile(b.journalFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var applied, bad int
+	lines := bytes.Split(data, []byte("\n"))
+	for i, raw := range lines {
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		var e journalEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			if i == len(lines)-1 { // no trailing newline: the write was cut off
+				b.logWarn("⚠️ Dropped incomplete last journal entry (line %d)", i+1)
+				break
+			}
+			b.logError("🚨 %s:%d: unreadable journal entry: %v", b.journalFile(), i+1, err)
+			bad++
+			continue
+		}
+		b.apply(e)
+		applied++
+	}
+	if applied > 0 || bad > 0 {
+		b.log("📓 Replayed %d journal entries from an interrupted run", applied)
+	}
+	return nil
+}
+
+// truncateJournal empties the journal once its entries are in the state file.
+func (b *Bot) truncateJournal() error {
+	if b.journal != nil {
+		b.journal.Close()
+		b.journal = nil
+	}
+	if err := os.Truncate(b.journalFile(), 0); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
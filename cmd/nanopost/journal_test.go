@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplayJournal(t *testing.T) {
+	tests := []struct {
+		name         string
+		journal      string
+		wantComments []int
+		wantReply    map[int]int
+		wantTopic    int
+	}{
+		{
+			name:         "complete entries",
+			journal:      `{"kind":"comment","id":1}` + "\n" + `{"kind":"comment","id":2}` + "\n",
+			wantComments: []int{1, 2},
+		},
+		{
+			name:         "reply links our comment to theirs",
+			journal:      `{"kind":"comment","id":1}` + "\n" + `{"kind":"reply","id":1,"ref":555}` + "\n",
+			wantComments: []int{1},
+			wantReply:    map[int]int{1: 555},
+		},
+		{
+			name:         "torn last line",
+			journal:      `{"kind":"comment","id":1}` + "\n" + `{"kind":"comment","i`,
+			wantComments: []int{1},
+		},
+		{
+			name:         "damaged line in the middle",
+			journal:      `{"kind":"comment","id":1}` + "\n" + `{oops}` + "\n" + `{"kind":"comment","id":2}` + "\n",
+			wantComments: []int{1, 2},
+		},
+		{
+			name:         "blank lines",
+			journal:      "\n\n" + `{"kind":"comment","id":3}` + "\n\n",
+			wantComments: []int{3},
+		},
+		{
+			name:      "new post moves the topic",
+			journal:   `{"time":"2026-01-02T03:04:05Z","kind":"new_post","id":4}` + "\n",
+			wantTopic: 4,
+		},
+		{name: "empty", journal: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := stateBot(filepath.Join(t.TempDir(), "state.json"))
+			if err := os.WriteFile(b.journalFile(), []byte(tt.journal), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.replayJournal(); err != nil {
+				t.Fatalf("replayJournal: %v", err)
+			}
+			if len(b.seen.comments) != len(tt.wantComments) {
+				t.Errorf("comments = %v, want %v", b.seen.comments, tt.wantComments)
+			}
+			for _, id := range tt.wantComments {
+				if !b.seen.has("comment", id, "") {
+					t.Errorf("comment %d not replayed", id)
+				}
+			}
+			for theirs, ours := range tt.wantReply {
+				if b.seen.replies[theirs] != ours {
+					t.Errorf("reply to %d = %d, want %d", theirs, b.seen.replies[theirs], ours)
+				}
+			}
+			if b.topicIndex != tt.wantTopic {
+				t.Errorf("topicIndex = %d, want %d", b.topicIndex, tt.wantTopic)
+			}
+		})
+	}
+}
+
+// A kill between rounds leaves the side effects in the journal; the next
+// start must know about them, and saveState must empty the journal.
+func TestJournalSurvivesRestart(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	b := stateBot(stateFile)
+	b.remember("comment", 7, "")
+	b.remember("agent", 0, "alice")
+	b.remember("progress", 0, "")
+	b.journal.Close()
+
+	restarted := stateBot(stateFile)
+	if err := restarted.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.replayJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.seen.has("comment", 7, "") || !restarted.seen.has("agent", 0, "alice") {
+		t.Errorf("journal not replayed: %+v", restarted.seen)
+	}
+	if time.Since(restarted.lastProgressPost) > time.Minute {
+		t.Errorf("lastProgressPost = %v, want about now", restarted.lastProgressPost)
+	}
+
+	if err := restarted.saveState(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(restarted.journalFile()); err != nil || len(data) != 0 {
+		t.Errorf("journal after saveState = %q (%v), want empty", data, err)
+	}
+	again := stateBot(stateFile)
+	if err := again.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if !again.seen.has("comment", 7, "") {
+		t.Error("comment 7 missing from the saved state")
+	}
+}
+
+func TestDryRunDoesNotJournal(t *testing.T) {
+	b := stateBot(filepath.Join(t.TempDir(), "state.json"))
+	b.dryRun = true
+	b.remember("comment", 1, "")
+	if !b.seen.has("comment", 1, "") {
+		t.Error("dry run did not remember the comment in memory")
+	}
+	if _, err := os.Stat(b.journalFile()); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote a journal (stat: %v)", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"nanopost/internal/colosseum"
+)
+
+// ==================== Engagement Judge ====================
+//
+// With judge.enabled, engage asks the model whether a relevant post really
+// fits the agent's mission before writing a comment, so the few engagements
+// per round go to the posts that matter.
+
+type JudgeConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	MinConfidence float64 `yaml:"min_confidence"` // "engage" answers below this count as "no"
+}
+
+// EngageDecision is the judge's answer for one post, kept in the round
+// summary.
+type EngageDecision struct {
+	PostID     int     `json:"post_id"`
+	Title      string  `json:"title"`
+	AgentName  string  `json:"agent_name"`
+	Engage     bool    `json:"engage"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+}
+
+// defaultJudgePrompt is used when prompts.yaml has no judge template.
+const defaultJudgePrompt = `Decide whether I should comment on another agent's forum post.
+
+My mission:
+{{.Mission}}
+
+Their post:
+Title: {{.Title}}
+Author: @{{.AgentName}}
+Content: {{.Body}}
+
+Engage only if the post genuinely relates to my mission and a comment from me would add something for its author. Skip off-topic posts, announcements with nothing to discuss and anything spammy.
+
+Answer with JSON only, no other text:
+{"engage": true or false, "confidence": 0.0 to 1.0, "reason": "one sentence"}`
+
+// judgeEngagement asks the model about p. An answer that isn't the JSON
+// asked for is an error.
+func (b *Bot) judgeEngagement(ctx context.Context, p colosseum.Post) (EngageDecision, error) {
+	d := EngageDecision{PostID: p.ID, Title: p.Title, AgentName: p.AgentName}
+	tmpl := b.prompts.Judge
+	if strings.TrimSpace(tmpl) == "" {
+		tmpl = defaultJudgePrompt
+	}
+	mission := strings.TrimSpace(b.prompts.Mission)
+	if mission == "" {
+		mission = strings.TrimSpace(b.prompts.System)
+	}
+	prompt := b.renderPrompt(tmpl, map[string]string{
+		"Mission": mission, "Title": p.Title, "AgentName": p.AgentName, "Body": truncate(p.Body, 1500),
+	})
+	answer, err := b.callAI(ctx, prompt)
+	if err != nil {
+		return d, err
+	}
+	if err := parseDecision(answer, &d); err != nil {
+		return d, fmt.Errorf("%w in %q", err, truncate(answer, 200))
+	}
+	return d, nil
+}
+
+// parseDecision reads the first JSON object in answer, so code fences or a
+// sentence around it don't matter.
+func parseDecision(answer string, d *EngageDecision) error {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("no JSON object")
+	}
+	var out struct {
+		Engage     *bool   `json:"engage"`
+		Confidence float64 `json:"confidence"`
+		Reason     string  `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &out); err != nil {
+		return err
+	}
+	if out.Engage == nil {
+		return fmt.Errorf("no \"engage\" field")
+	}
+	d.Engage = *out.Engage
+	d.Confidence = math.Min(math.Max(out.Confidence, 0), 1)
+	d.Reason = strings.TrimSpace(out.Reason)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDecision(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   EngageDecision
+		errHas string // "" = must parse
+	}{
+		{
+			name:   "bare JSON",
+			answer: `{"engage": true, "confidence": 0.8, "reason": "on topic"}`,
+			want:   EngageDecision{Engage: true, Confidence: 0.8, Reason: "on topic"},
+		},
+		{
+			name:   "code fence",
+			answer: "```json\n{\"engage\": false, \"confidence\": 0.3, \"reason\": \" spam \"}\n```",
+			want:   EngageDecision{Confidence: 0.3, Reason: "spam"},
+		},
+		{
+			name:   "prose around it",
+			answer: `Sure! Here is my verdict: {"engage": true, "confidence": 0.6, "reason": "asks about identity"} Hope that helps.`,
+			want:   EngageDecision{Engage: true, Confidence: 0.6, Reason: "asks about identity"},
+		},
+		{
+			name:   "confidence clamped",
+			answer: `{"engage": true, "confidence": 7}`,
+			want:   EngageDecision{Engage: true, Confidence: 1},
+		},
+		{
+			name:   "negative confidence clamped",
+			answer: `{"engage": false, "confidence": -0.5}`,
+			want:   EngageDecision{},
+		},
+		{name: "no JSON", answer: "I would engage with this post.", errHas: "no JSON object"},
+		{name: "closing brace first", answer: "} then {", errHas: "no JSON object"},
+		{name: "engage missing", answer: `{"confidence": 0.9}`, errHas: `no "engage" field`},
+		{name: "truncated", answer: `{"engage": true, "confidence": 0.9, "reason": "cut off}`, errHas: "unexpected end"},
+		{name: "wrong type", answer: `{"engage": "yes"}`, errHas: "cannot unmarshal"},
+		{name: "two objects", answer: `{"engage": true} or {"engage": false}`, errHas: "invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := EngageDecision{PostID: 3, Title: "kept"}
+			err := parseDecision(tt.answer, &d)
+			if tt.errHas != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+					t.Fatalf("err = %v, want one mentioning %q", err, tt.errHas)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.PostID, tt.want.Title = 3, "kept"
+			if d != tt.want {
+				t.Errorf("decision = %+v, want %+v", d, tt.want)
+			}
+		})
+	}
+}
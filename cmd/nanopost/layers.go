@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ==================== Layered Config ====================
+//
+// The effective config is built in four layers, each overriding the last:
+//
+//	1. built-in defaults (defaultConfig)
+//	2. config/config.yaml
+//	3. NANOPOST_* environment variables, e.g. NANOPOST_BOT_MAX_ENGAGEMENTS_PER_CYCLE=3
+//	4. command-line flags, e.g. --bot.max_engagements_per_cycle=3
+//
+// Keys are the dotted yaml paths of Config. Lists are comma-separated.
+
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// configSources maps every dotted key to the layer its value came from.
+type configSources map[string]string
+
+// configField is one settable leaf of Config.
+type configField struct {
+	Key   string // dotted yaml path
+	Value reflect.Value
+}
+
+// configFields lists the leaves of c in declaration order. Lists of
+// structs (agents) and maps (keyword weights) can only be set in the file.
+func configFields(c *Config) []configField {
+	var fields []configField
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			key := prefix + name
+			f := v.Field(i)
+			switch {
+			case f.Kind() == reflect.Struct:
+				walk(f, key+".")
+			case f.Kind() == reflect.Slice && f.Type().Elem().Kind() != reflect.String:
+			case f.Kind() == reflect.Map:
+			default:
+				fields = append(fields, configField{Key: key, Value: f})
+			}
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return fields
+}
+
+func envName(key string) string {
+	return "NANOPOST_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func setField(f reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("want an integer, got %q", raw)
+		}
+		f.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("want a number, got %q", raw)
+		}
+		f.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("want true or false, got %q", raw)
+		}
+		f.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	case reflect.Ptr: // optional value, e.g. api.llm.temperature
+		v := reflect.New(f.Type().Elem())
+		if err := setField(v.Elem(), raw); err != nil {
+			return err
+		}
+		f.Set(v)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
+
+func formatField(f reflect.Value) string {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return "unset"
+		}
+		f = f.Elem()
+	}
+	if f.Kind() == reflect.Slice {
+		items := make([]string, f.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(f.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	if f.Kind() == reflect.String {
+		return strconv.Quote(f.String())
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// readConfig builds the effective config: defaults, then the file at path
+// (a missing file is fine), then environment variables, then overrides.
+// The result is validated; data is the raw file for line-numbered errors.
+func readConfig(path string, overrides map[string]string) (Config, configSources, error) {
+	c := defaultConfig()
+	sources := configSources{}
+	fields := configFields(&c)
+	for _, f := range fields {
+		sources[f.Key] = sourceDefault
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return c, sources, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return c, sources, fmt.Errorf("%s: %w", path, err)
+		}
+		v, err := newValidator(path, data)
+		if err != nil {
+			return c, sources, err
+		}
+		for _, f := range fields {
+			if _, node := v.lookup(f.Key); node != nil {
+				sources[f.Key] = sourceFile
+			}
+		}
+	}
+
+	var issues ConfigIssues
+	for _, f := range fields {
+		if raw, ok := os.LookupEnv(envName(f.Key)); ok {
+			if err := setField(f.Value, raw); err != nil {
+				issues = append(issues, ConfigIssue{File: "env " + envName(f.Key), Path: f.Key, Msg: err.Error()})
+				continue
+			}
+			sources[f.Key] = sourceEnv
+		}
+	}
+	byKey := map[string]configField{}
+	for _, f := range fields {
+		byKey[f.Key] = f
+	}
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		raw := overrides[key]
+		f, ok := byKey[key]
+		if !ok {
+			issues = append(issues, ConfigIssue{File: "flag --" + key, Path: key, Msg: "unknown config key"})
+			continue
+		}
+		if err := setField(f.Value, raw); err != nil {
+			issues = append(issues, ConfigIssue{File: "flag --" + key, Path: key, Msg: err.Error()})
+			continue
+		}
+		sources[key] = sourceFlag
+	}
+	if len(issues) > 0 {
+		return c, sources, issues
+	}
+	return c, sources, validateConfig(path, data, c, sources)
+}
+
+// parseConfigFlags pulls --key=value / --key value config overrides out of
+// args and returns the remaining positional arguments. A bare boolean flag
+// (--posting.enabled) means true.
+func parseConfigFlags(args []string) (rest []string, overrides map[string]string, err error) {
+	var probe Config
+	kinds := map[string]reflect.Kind{}
+	for _, f := range configFields(&probe) {
+		kinds[f.Key] = f.Value.Kind()
+	}
+	overrides = map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			rest = append(rest, arg)
+			continue
+		}
+		key, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		kind, ok := kinds[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown flag --%s (see `nanopost config print` for keys)", key)
+		}
+		if !hasValue {
+			if kind == reflect.Bool {
+				value = "true"
+				if i+1 < len(args) {
+					if _, err := strconv.ParseBool(args[i+1]); err == nil {
+						i++
+						value = args[i]
+					}
+				}
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			} else {
+				return nil, nil, fmt.Errorf("flag --%s needs a value", key)
+			}
+		}
+		overrides[key] = value
+	}
+	return rest, overrides, nil
+}
+
+// printConfig implements `nanopost config print`: every effective value
+// with the layer it came from and the env var that would override it.
+func printConfig(c Config, sources configSources) {
+	fields := configFields(&c)
+	width := 0
+	for _, f := range fields {
+		if len(f.Key) > width {
+			width = len(f.Key)
+		}
+	}
+	counts := map[string]int{}
+	for _, f := range fields {
+		src := sources[f.Key]
+		counts[src]++
+		fmt.Printf("%-*s = %-40s # %-7s %s\n", width, f.Key, formatField(f.Value), src, envName(f.Key))
+	}
+	var summary []string
+	for src, n := range counts {
+		summary = append(summary, fmt.Sprintf("%s: %d", src, n))
+	}
+	sort.Strings(summary)
+	fmt.Printf("\n# precedence: default < file < env < flag | %s\n", strings.Join(summary, ", "))
+}
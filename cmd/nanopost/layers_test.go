@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const layersYAML = `agent:
+  project_id: 7
+bot:
+  default_interval_minutes: 10
+  max_engagements_per_cycle: 3
+progress:
+  hackathon_start_date: 2026-02-02
+`
+
+func TestLayerPrecedence(t *testing.T) {
+	path := writeTemp(t, "config.yaml", layersYAML)
+	t.Setenv("NANOPOST_BOT_MAX_ENGAGEMENTS_PER_CYCLE", "4")
+	t.Setenv("NANOPOST_BOT_DEFAULT_INTERVAL_MINUTES", "15")
+	t.Setenv("NANOPOST_KEYWORDS", "alpha, beta,,gamma,delta")
+
+	c, sources, err := readConfig(path, map[string]string{"bot.max_engagements_per_cycle": "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		key    string
+		got    interface{}
+		want   interface{}
+		source string
+	}{
+		{"agent.name", c.Agent.Name, "moltpost-agent", sourceDefault},
+		{"agent.project_id", c.Agent.ProjectID, 7, sourceFile},
+		{"bot.default_interval_minutes", c.Bot.DefaultInterval, 15, sourceEnv},
+		{"bot.max_engagements_per_cycle", c.Bot.MaxEngagements, 5, sourceFlag},
+		{"keywords", c.Keywords, []string{"alpha", "beta", "gamma", "delta"}, sourceEnv},
+	}
+	for _, ck := range checks {
+		if !reflect.DeepEqual(ck.got, ck.want) || sources[ck.key] != ck.source {
+			t.Errorf("%s = %v from %s, want %v from %s", ck.key, ck.got, sources[ck.key], ck.want, ck.source)
+		}
+	}
+}
+
+func TestMissingFileKeepsDefaults(t *testing.T) {
+	t.Setenv("NANOPOST_AGENT_PROJECT_ID", "1")
+	t.Setenv("NANOPOST_PROGRESS_HACKATHON_START_DATE", "2026-02-02")
+	c, sources, err := readConfig(t.TempDir()+"/missing.yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Bot.DefaultInterval != 30 || sources["bot.default_interval_minutes"] != sourceDefault {
+		t.Errorf("interval = %d from %s", c.Bot.DefaultInterval, sources["bot.default_interval_minutes"])
+	}
+}
+
+func TestOptionalTemperature(t *testing.T) {
+	c, sources, err := readConfig(writeTemp(t, "config.yaml", layersYAML), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.API.LLM.Temperature != nil {
+		t.Errorf("temperature = %v, want unset by default", *c.API.LLM.Temperature)
+	}
+
+	c, sources, err = readConfig(writeTemp(t, "config.yaml", layersYAML+"api:\n  llm:\n    temperature: 0\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.API.LLM.Temperature == nil || *c.API.LLM.Temperature != 0 || sources["api.llm.temperature"] != sourceFile {
+		t.Errorf("temperature: 0 in the file = %v from %s, want 0", c.API.LLM.Temperature, sources["api.llm.temperature"])
+	}
+
+	t.Setenv("NANOPOST_API_LLM_TEMPERATURE", "1.5")
+	c, _, err = readConfig(writeTemp(t, "config.yaml", layersYAML), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.API.LLM.Temperature == nil || *c.API.LLM.Temperature != 1.5 {
+		t.Errorf("temperature from env = %v, want 1.5", c.API.LLM.Temperature)
+	}
+	if _, _, err := readConfig(writeTemp(t, "config.yaml", layersYAML), map[string]string{"api.llm.temperature": "3"}); err == nil {
+		t.Error("temperature 3 accepted")
+	}
+}
+
+func TestOverrideIssuesNameTheirSource(t *testing.T) {
+	path := writeTemp(t, "config.yaml", layersYAML)
+	t.Setenv("NANOPOST_BOT_DEFAULT_INTERVAL_MINUTES", "soon")
+	_, _, err := readConfig(path, map[string]string{"bot.nope": "1", "bot.max_engagements_per_cycle": "0"})
+	var issues ConfigIssues
+	if !errors.As(err, &issues) {
+		t.Fatalf("err = %v, want ConfigIssues", err)
+	}
+	files := map[string]bool{}
+	for _, is := range issues {
+		files[is.File] = true
+	}
+	if !files["env NANOPOST_BOT_DEFAULT_INTERVAL_MINUTES"] || !files["flag --bot.nope"] {
+		t.Errorf("issues = %v, want the env var and the unknown flag named", err)
+	}
+}
+
+func TestParseConfigFlags(t *testing.T) {
+	tests := []struct {
+		args      []string
+		rest      []string
+		overrides map[string]string
+		wantErr   bool
+	}{
+		{[]string{"once"}, []string{"once"}, map[string]string{}, false},
+		{[]string{"--bot.max_engagements_per_cycle=3", "once"}, []string{"once"},
+			map[string]string{"bot.max_engagements_per_cycle": "3"}, false},
+		{[]string{"--agent.name", "bob", "15"}, []string{"15"}, map[string]string{"agent.name": "bob"}, false},
+		// A bare boolean means true; a following bool literal is its value.
+		{[]string{"--posting.enabled", "once"}, []string{"once"}, map[string]string{"posting.enabled": "true"}, false},
+		{[]string{"--posting.enabled", "false"}, nil, map[string]string{"posting.enabled": "false"}, false},
+		{[]string{"--no.such.key=1"}, nil, nil, true},
+		{[]string{"--agent.name"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		rest, overrides, err := parseConfigFlags(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: err = %v", tt.args, err)
+			continue
+		}
+		if !tt.wantErr && (!reflect.DeepEqual(rest, tt.rest) || !reflect.DeepEqual(overrides, tt.overrides)) {
+			t.Errorf("%v: rest = %v, overrides = %v", tt.args, rest, overrides)
+		}
+	}
+}
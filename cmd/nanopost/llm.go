@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"nanopost/internal/ratelimit"
+	"nanopost/internal/retry"
+)
+
+// ==================== LLM Providers ====================
+
+// ChatMessage is a single turn in a chat conversation, shared by all providers.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// LLMProvider generates a completion for a list of chat messages.
+// The first message may carry the "system" role; providers that take the
+// system prompt separately (Anthropic) lift it out themselves.
+type LLMProvider interface {
+	Name() string
+	Model() string
+	Chat(ctx context.Context, messages []ChatMessage) (string, error)
+}
+
+type LLMConfig struct {
+	Provider    string   `yaml:"provider"` // zhipu | openai | anthropic | ollama
+	URL         string   `yaml:"url"`
+	Model       string   `yaml:"model"`
+	APIKeyEnv   string   `yaml:"api_key_env"`
+	MaxTokens   int      `yaml:"max_tokens"`
+	Temperature *float64 `yaml:"temperature"` // nil = the provider's default; 0 is sent as 0
+}
+
+// llmDefaults holds the per-provider fallbacks used when a field of the
+// api.llm block is left empty.
+var llmDefaults = map[string]LLMConfig{
+	"zhipu":     {URL: "https://open.bigmodel.cn/api/paas/v4/chat/completions", Model: "glm-4-flash", APIKeyEnv: "ZHIPU_API_KEY"},
+	"openai":    {URL: "https://api.openai.com/v1/chat/completions", Model: "gpt-4o-mini", APIKeyEnv: "OPENAI_API_KEY"},
+	"anthropic": {URL: "https://api.anthropic.com/v1/messages", Model: "claude-3-5-haiku-latest", APIKeyEnv: "ANTHROPIC_API_KEY", MaxTokens: 1024},
+	"ollama":    {URL: "http://localhost:11434/api/chat", Model: "llama3.1"},
+}
+
+// resolveLLMConfig fills the empty fields of the api.llm block. When no
+// provider is set the legacy zhipu_url / zhipu_model keys are honoured so
+// existing config files keep working unchanged.
+func resolveLLMConfig(c Config) (LLMConfig, error) {
+	lc := c.API.LLM
+	lc.Provider = strings.ToLower(strings.TrimSpace(lc.Provider))
+	if lc.Provider == "" {
+		lc.Provider = "zhipu"
+	}
+	def, ok := llmDefaults[lc.Provider]
+	if !ok {
+		return lc, fmt.Errorf("unknown llm provider %q (want zhipu, openai, anthropic or ollama)", lc.Provider)
+	}
+	if lc.Provider == "zhipu" {
+		if c.API.ZhipuURL != "" {
+			def.URL = c.API.ZhipuURL
+		}
+		if c.API.ZhipuModel != "" {
+			def.Model = c.API.ZhipuModel
+		}
+	}
+	if lc.URL == "" {
+		lc.URL = def.URL
+	}
+	if lc.Model == "" {
+		lc.Model = def.Model
+	}
+	if lc.APIKeyEnv == "" {
+		lc.APIKeyEnv = def.APIKeyEnv
+	}
+	if lc.MaxTokens == 0 {
+		lc.MaxTokens = def.MaxTokens
+	}
+	return lc, nil
+}
+
+// newLLMProvider builds the provider selected in config. An API key is
+// required for every provider except a local Ollama server.
+func newLLMProvider(c Config, client *http.Client) (LLMProvider, error) {
+	lc, err := resolveLLMConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	var apiKey string
+	if lc.APIKeyEnv != "" {
+		apiKey = os.Getenv(lc.APIKeyEnv)
+		if apiKey == "" && lc.Provider != "ollama" {
+			return nil, fmt.Errorf("%s required for llm provider %s", lc.APIKeyEnv, lc.Provider)
+		}
+	}
+	switch lc.Provider {
+	case "anthropic":
+		return &anthropicProvider{cfg: lc, apiKey: apiKey, client: client}, nil
+	case "ollama":
+		return &ollamaProvider{cfg: lc, client: client}, nil
+	default: // zhipu speaks the OpenAI chat-completions shape
+		return &openAIProvider{name: lc.Provider, cfg: lc, apiKey: apiKey, client: client}, nil
+	}
+}
+
+// llmHTTPError is a non-2xx response from an LLM endpoint.
+type llmHTTPError struct {
+	URL        string
+	StatusCode int
+	Body       string
+	retryAfter time.Duration
+}
+
+func (e *llmHTTPError) Error() string {
+	return fmt.Sprintf("%s: HTTP %d: %s", e.URL, e.StatusCode, truncate(e.Body, 200))
+}
+
+func (e *llmHTTPError) Retryable() bool           { return retry.TemporaryStatus(e.StatusCode) }
+func (e *llmHTTPError) RetryAfter() time.Duration { return e.retryAfter }
+
+// retryingProvider repeats transient LLM failures according to a policy.
+type retryingProvider struct {
+	LLMProvider
+	policy retry.Policy
+}
+
+func (p *retryingProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	var out string
+	err := p.policy.Do(ctx, func() error {
+		var err error
+		out, err = p.LLMProvider.Chat(ctx, messages)
+		return err
+	})
+	return out, err
+}
+
+// limitedProvider waits for the "llm" rate-limit bucket before each call.
+type limitedProvider struct {
+	LLMProvider
+	limiter *ratelimit.Limiter
+}
+
+func (p *limitedProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	if err := p.limiter.Wait(ctx, "llm"); err != nil {
+		return "", err
+	}
+	out, err := p.LLMProvider.Chat(ctx, messages)
+	p.limiter.Done("llm", err == nil)
+	return out, err
+}
+
+// postJSON sends a JSON body and returns the response body, treating any
+// non-2xx status as an error.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &llmHTTPError{
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			retryAfter: retry.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	return body, nil
+}
+
+// ---------- OpenAI-compatible (OpenAI, Zhipu, vLLM, ...) ----------
+
+type openAIRequest struct {
+	Model       string        `json:"model"`
+	Messages    []ChatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message ChatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+type openAIProvider struct {
+	name   string
+	cfg    LLMConfig
+	apiKey string
+	client *http.Client
+}
+
+func (p *openAIProvider) Name() string  { return p.name }
+func (p *openAIProvider) Model() string { return p.cfg.Model }
+
+func (p *openAIProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	body, err := postJSON(ctx, p.client, p.cfg.URL, headers, openAIRequest{
+		Model:       p.cfg.Model,
+		Messages:    messages,
+		MaxTokens:   p.cfg.MaxTokens,
+		Temperature: p.cfg.Temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	var r openAIResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", fmt.Errorf("%s: decode response: %w", p.name, err)
+	}
+	if len(r.Choices) == 0 {
+		return "", fmt.Errorf("%s: no response", p.name)
+	}
+	return r.Choices[0].Message.Content, nil
+}
+
+// ---------- Anthropic Messages ----------
+
+type anthropicRequest struct {
+	Model       string        `json:"model"`
+	System      string        `json:"system,omitempty"`
+	Messages    []ChatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens"`
+	Temperature *float64      `json:"temperature,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+type anthropicProvider struct {
+	cfg    LLMConfig
+	apiKey string
+	client *http.Client
+}
+
+func (p *anthropicProvider) Name() string  { return "anthropic" }
+func (p *anthropicProvider) Model() string { return p.cfg.Model }
+
+func (p *anthropicProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	req := anthropicRequest{Model: p.cfg.Model, MaxTokens: p.cfg.MaxTokens, Temperature: p.cfg.Temperature}
+	for _, m := range messages {
+		if m.Role == "system" {
+			req.System = strings.TrimSpace(req.System + "\n\n" + m.Content)
+			continue
+		}
+		req.Messages = append(req.Messages, m)
+	}
+	body, err := postJSON(ctx, p.client, p.cfg.URL, map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}, req)
+	if err != nil {
+		return "", err
+	}
+	var r anthropicResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", fmt.Errorf("anthropic: decode response: %w", err)
+	}
+	var sb strings.Builder
+	for _, c := range r.Content {
+		if c.Type == "text" {
+			sb.WriteString(c.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("anthropic: no response")
+	}
+	return sb.String(), nil
+}
+
+// ---------- Ollama ----------
+
+type ollamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  struct {
+		Temperature *float64 `json:"temperature,omitempty"`
+		NumPredict  int      `json:"num_predict,omitempty"`
+	} `json:"options"`
+}
+
+type ollamaResponse struct {
+	Message ChatMessage `json:"message"`
+}
+
+type ollamaProvider struct {
+	cfg    LLMConfig
+	client *http.Client
+}
+
+func (p *ollamaProvider) Name() string  { return "ollama" }
+func (p *ollamaProvider) Model() string { return p.cfg.Model }
+
+func (p *ollamaProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	req := ollamaRequest{Model: p.cfg.Model, Messages: messages}
+	req.Options.Temperature = p.cfg.Temperature
+	req.Options.NumPredict = p.cfg.MaxTokens
+	body, err := postJSON(ctx, p.client, p.cfg.URL, nil, req)
+	if err != nil {
+		return "", err
+	}
+	var r ollamaResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", fmt.Errorf("ollama: decode response: %w", err)
+	}
+	if r.Message.Content == "" {
+		return "", fmt.Errorf("ollama: no response")
+	}
+	return r.Message.Content, nil
+}
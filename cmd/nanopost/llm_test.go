@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeLLM serves one canned response and keeps the last request it got.
+type fakeLLM struct {
+	status int
+	reply  string
+	header http.Header
+	body   map[string]interface{}
+}
+
+func (f *fakeLLM) start(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.header = r.Header.Clone()
+		data, _ := io.ReadAll(r.Body)
+		f.body = nil
+		if err := json.Unmarshal(data, &f.body); err != nil {
+			t.Errorf("request is not JSON: %s", data)
+		}
+		if f.status != 0 {
+			w.WriteHeader(f.status)
+		}
+		io.WriteString(w, f.reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var testConversation = []ChatMessage{
+	{Role: "system", Content: "You are terse."},
+	{Role: "user", Content: "Hi"},
+}
+
+func TestOpenAIProviderEncoding(t *testing.T) {
+	f := &fakeLLM{reply: `{"choices":[{"message":{"role":"assistant","content":"Hello!"}}]}`}
+	srv := f.start(t)
+	temp := 0.7
+	p := &openAIProvider{name: "openai", apiKey: "sk-test", client: srv.Client(),
+		cfg: LLMConfig{URL: srv.URL, Model: "gpt-test", MaxTokens: 50, Temperature: &temp}}
+
+	got, err := p.Chat(context.Background(), testConversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hello!" {
+		t.Errorf("Chat = %q, want Hello!", got)
+	}
+	if auth := f.header.Get("Authorization"); auth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if f.body["model"] != "gpt-test" || f.body["max_tokens"] != 50.0 || f.body["temperature"] != 0.7 {
+		t.Errorf("body = %v", f.body)
+	}
+	if msgs := f.body["messages"].([]interface{}); len(msgs) != 2 {
+		t.Errorf("messages = %v, want the system and user turns as given", msgs)
+	}
+}
+
+func TestAnthropicProviderEncoding(t *testing.T) {
+	f := &fakeLLM{reply: `{"content":[{"type":"text","text":"Hel"},{"type":"tool_use"},{"type":"text","text":"lo"}]}`}
+	srv := f.start(t)
+	p := &anthropicProvider{apiKey: "ak-test", client: srv.Client(),
+		cfg: LLMConfig{URL: srv.URL, Model: "claude-test", MaxTokens: 1024}}
+
+	got, err := p.Chat(context.Background(), testConversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hello" {
+		t.Errorf("Chat = %q, want the text blocks joined", got)
+	}
+	if f.header.Get("x-api-key") != "ak-test" || f.header.Get("anthropic-version") == "" {
+		t.Errorf("headers = %v", f.header)
+	}
+	if f.body["system"] != "You are terse." {
+		t.Errorf("system = %v, want the system turn lifted out", f.body["system"])
+	}
+	msgs := f.body["messages"].([]interface{})
+	if len(msgs) != 1 || msgs[0].(map[string]interface{})["role"] != "user" {
+		t.Errorf("messages = %v, want only the user turn", msgs)
+	}
+	if _, ok := f.body["temperature"]; ok {
+		t.Errorf("temperature sent although not configured: %v", f.body)
+	}
+}
+
+func TestOllamaProviderEncoding(t *testing.T) {
+	f := &fakeLLM{reply: `{"message":{"role":"assistant","content":"Hello!"}}`}
+	srv := f.start(t)
+	temp := 0.2
+	p := &ollamaProvider{client: srv.Client(), cfg: LLMConfig{URL: srv.URL, Model: "llama-test", MaxTokens: 64, Temperature: &temp}}
+
+	got, err := p.Chat(context.Background(), testConversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Hello!" {
+		t.Errorf("Chat = %q", got)
+	}
+	if f.body["stream"] != false {
+		t.Errorf("stream = %v, want false", f.body["stream"])
+	}
+	opts := f.body["options"].(map[string]interface{})
+	if opts["temperature"] != 0.2 || opts["num_predict"] != 64.0 {
+		t.Errorf("options = %v", opts)
+	}
+}
+
+func TestZeroTemperatureIsSent(t *testing.T) {
+	f := &fakeLLM{reply: `{"choices":[{"message":{"content":"ok"}}]}`}
+	srv := f.start(t)
+	zero := 0.0
+	p := &openAIProvider{name: "openai", client: srv.Client(), cfg: LLMConfig{URL: srv.URL, Model: "m", Temperature: &zero}}
+	if _, err := p.Chat(context.Background(), testConversation); err != nil {
+		t.Fatal(err)
+	}
+	if temp, ok := f.body["temperature"]; !ok || temp != 0.0 {
+		t.Errorf("body = %v, want temperature 0 sent", f.body)
+	}
+
+	p.cfg.Temperature = nil
+	if _, err := p.Chat(context.Background(), testConversation); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.body["temperature"]; ok {
+		t.Errorf("body = %v, want no temperature when unset", f.body)
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	cases := []struct {
+		provider string
+		status   int
+		reply    string
+		want     string // substring of the error
+	}{
+		{"openai", 401, `{"error":"bad key"}`, "HTTP 401"},
+		{"openai", 0, `{"choices":[]}`, "no response"},
+		{"openai", 0, `<html>`, "decode response"},
+		{"anthropic", 0, `{"content":[]}`, "no response"},
+		{"anthropic", 529, `overloaded`, "HTTP 529"},
+		{"ollama", 0, `{"message":{"content":""}}`, "no response"},
+	}
+	for _, c := range cases {
+		f := &fakeLLM{status: c.status, reply: c.reply}
+		srv := f.start(t)
+		lc := LLMConfig{URL: srv.URL, Model: "m"}
+		var p LLMProvider
+		switch c.provider {
+		case "openai":
+			p = &openAIProvider{name: "openai", cfg: lc, client: srv.Client()}
+		case "anthropic":
+			p = &anthropicProvider{cfg: lc, client: srv.Client()}
+		case "ollama":
+			p = &ollamaProvider{cfg: lc, client: srv.Client()}
+		}
+		_, err := p.Chat(context.Background(), testConversation)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s %d %s: err = %v, want %q", c.provider, c.status, c.reply, err, c.want)
+		}
+	}
+}
+
+func TestResolveLLMConfig(t *testing.T) {
+	var c Config
+	c.API.ZhipuURL = "https://legacy.example/chat"
+	lc, err := resolveLLMConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lc.Provider != "zhipu" || lc.URL != "https://legacy.example/chat" || lc.Model != "glm-4-flash" {
+		t.Errorf("no provider = %+v, want zhipu with the legacy URL", lc)
+	}
+
+	c.API.LLM = LLMConfig{Provider: " Anthropic ", Model: "claude-custom"}
+	lc, err = resolveLLMConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lc.URL != llmDefaults["anthropic"].URL || lc.Model != "claude-custom" || lc.MaxTokens != 1024 {
+		t.Errorf("anthropic = %+v, want defaults with the configured model", lc)
+	}
+
+	c.API.LLM.Provider = "gemini"
+	if _, err := resolveLLMConfig(c); err == nil {
+		t.Error("unknown provider accepted")
+	}
+}
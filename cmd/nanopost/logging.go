@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"nanopost/internal/logfile"
+)
+
+// ==================== Logging ====================
+//
+// Every line goes through one slog.Logger per bot: the log file gets it as
+// JSON or logfmt text with agent, action and post attributes, the console
+// keeps the short "[time] message" form.
+
+// LoggingConfig configures levels, format and rotation of output.log_file.
+type LoggingConfig struct {
+	Level      string `yaml:"level"`        // debug | info | warn | error
+	Format     string `yaml:"format"`       // text | json (log file only; the console stays readable)
+	Rotate     string `yaml:"rotate"`       // size | daily | none
+	MaxSizeMB  int    `yaml:"max_size_mb"`  // size rotation threshold
+	MaxFiles   int    `yaml:"max_files"`    // rotated files kept; 0 = all
+	MaxAgeDays int    `yaml:"max_age_days"` // rotated files older than this are deleted; 0 = never
+}
+
+func (lc LoggingConfig) level() slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(lc.Level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+func (lc LoggingConfig) policy() logfile.Policy {
+	p := logfile.Policy{
+		MaxSize:  int64(lc.MaxSizeMB) << 20,
+		MaxFiles: lc.MaxFiles,
+		MaxAge:   time.Duration(lc.MaxAgeDays) * 24 * time.Hour,
+	}
+	if lc.Rotate != "none" {
+		p.Mode = lc.Rotate
+	}
+	return p
+}
+
+// consoleHandler prints the message only, as the bot always has. quiet
+// points at the bot's --json flag, which is set after the logger is built.
+type consoleHandler struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix string
+	level  slog.Leveler
+	quiet  *bool
+}
+
+var stdoutMu sync.Mutex // shared by the console handlers of all agents
+
+func (h *consoleHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return !*h.quiet && l >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintf(h.w, "[%s] %s%s\n", r.Time.Format("2006-01-02 15:04:05"), h.prefix, r.Message)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *consoleHandler) WithGroup(string) slog.Handler      { return h }
+
+// teeHandler sends each record to every handler that wants it.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// newLogger builds the bot's logger for c, writing to the console and file.
+func (b *Bot) newLogger(c Config, file io.Writer) *slog.Logger {
+	level := c.Logging.level()
+	console := &consoleHandler{w: os.Stdout, mu: &stdoutMu, prefix: b.logPrefix, level: level, quiet: &b.quiet}
+	var fileHandler slog.Handler
+	if c.Logging.Format == "json" {
+		fileHandler = slog.NewJSONHandler(file, &slog.HandlerOptions{Level: level, ReplaceAttr: plainMessage})
+	} else {
+		fileHandler = slog.NewTextHandler(file, &slog.HandlerOptions{Level: level})
+	}
+	return slog.New(teeHandler{console, fileHandler}).With("agent", c.Agent.Name)
+}
+
+// plainMessage drops the emoji that start console messages ("❌ Failed
+// ...") from the JSON log's msg, so it can be matched as plain text.
+func plainMessage(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.MessageKey {
+		a.Value = slog.StringValue(strings.TrimLeftFunc(a.Value.String(), func(r rune) bool {
+			return unicode.Is(unicode.So, r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) || unicode.IsSpace(r)
+		}))
+	}
+	return a
+}
+
+// emit logs at level with the current action and post attached.
+func (b *Bot) emit(level slog.Level, format string, args ...interface{}) {
+	logger := b.logger.Load()
+	if logger == nil || !logger.Enabled(context.Background(), level) {
+		return
+	}
+	var attrs []slog.Attr
+	if b.action != "" {
+		attrs = append(attrs, slog.String("action", b.action))
+	}
+	if b.post != 0 {
+		attrs = append(attrs, slog.Int("post", b.post))
+	}
+	logger.LogAttrs(context.Background(), level, fmt.Sprintf(format, args...), attrs...)
+}
+
+func (b *Bot) log(format string, args ...interface{})      { b.emit(slog.LevelInfo, format, args...) }
+func (b *Bot) logDebug(format string, args ...interface{}) { b.emit(slog.LevelDebug, format, args...) }
+func (b *Bot) logWarn(format string, args ...interface{})  { b.emit(slog.LevelWarn, format, args...) }
+func (b *Bot) logError(format string, args ...interface{}) { b.emit(slog.LevelError, format, args...) }
+
+// notice logs from another goroutine (the control API), so it leaves out
+// the action and post the bot's own goroutine is working on.
+func (b *Bot) notice(format string, args ...interface{}) {
+	if logger := b.logger.Load(); logger != nil {
+		logger.Info(fmt.Sprintf(format, args...))
+	}
+}
+
+// banner prints decoration to the console only; the log file gets the
+// structured lines around it.
+func (b *Bot) banner(line string) {
+	if b.quiet {
+		return
+	}
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	fmt.Printf("[%s] %s%s\n", time.Now().Format("2006-01-02 15:04:05"), b.logPrefix, line)
+}
+
+// focus sets the post the following lines are about; 0 clears it.
+func (b *Bot) focus(postID int) { b.post = postID }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogDropsEmoji(t *testing.T) {
+	var c Config
+	c.Agent.Name = "alpha"
+	c.Logging.Format = "json"
+	var file bytes.Buffer
+	b := &Bot{quiet: true, action: "reply", post: 186}
+	b.logger.Store(b.newLogger(c, &file))
+
+	b.logError("❌ Failed to save summary: %v", "disk full")
+	b.log("🗄️ Imported %d handled IDs", 3)
+	b.log("=== Round 2 ===")
+	b.logWarn("⚠️  No post ID in the response")
+
+	want := []string{"Failed to save summary: disk full", "Imported 3 handled IDs", "=== Round 2 ===", "No post ID in the response"}
+	lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("log file:\n%s", file.String())
+	}
+	for i, line := range lines {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec["msg"] != want[i] {
+			t.Errorf("msg = %q, want %q", rec["msg"], want[i])
+		}
+		if rec["agent"] != "alpha" || rec["action"] != "reply" || rec["post"] != 186.0 {
+			t.Errorf("attributes = %v", rec)
+		}
+	}
+}
+
+func TestTextLogKeepsMessage(t *testing.T) {
+	var c Config
+	c.Logging.Format = "text"
+	var file bytes.Buffer
+	b := &Bot{quiet: true}
+	b.logger.Store(b.newLogger(c, &file))
+	b.log("✅ Replied to @%s", "bob")
+	if !strings.Contains(file.String(), `msg="✅ Replied to @bob"`) {
+		t.Errorf("text log = %q", file.String())
+	}
+}
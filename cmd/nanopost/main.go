@@ -3,44 +3,51 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"nanopost/internal/colosseum"
+	"nanopost/internal/history"
+	"nanopost/internal/logfile"
+	"nanopost/internal/ratelimit"
+	"nanopost/internal/retry"
 )
 
 // ==================== Config ====================
 
 type Config struct {
 	API struct {
-		BaseURL    string `yaml:"base_url"`
-		ZhipuURL   string `yaml:"zhipu_url"`
-		ZhipuModel string `yaml:"zhipu_model"`
+		BaseURL    string    `yaml:"base_url"`
+		ZhipuURL   string    `yaml:"zhipu_url"`
+		ZhipuModel string    `yaml:"zhipu_model"`
+		LLM        LLMConfig `yaml:"llm"`
 	} `yaml:"api"`
-	Agent struct {
-		Name      string `yaml:"name"`
-		PostID    int    `yaml:"post_id"`
-		AgentID   int    `yaml:"agent_id"`
-		ProjectID int    `yaml:"project_id"`
-	} `yaml:"agent"`
-	Bot struct {
+	Agent  AgentProfile   `yaml:"agent"`
+	Agents []AgentProfile `yaml:"agents"` // several identities from one process; see agents.go
+	Bot    struct {
 		DefaultInterval int `yaml:"default_interval_minutes"`
 		MaxEngagements  int `yaml:"max_engagements_per_cycle"`
-		RateLimit       int `yaml:"rate_limit_seconds"`
-		EngageRateLimit int `yaml:"engage_rate_limit_seconds"`
+		WatchOwnPosts   int `yaml:"watch_own_posts"` // our newest N posts checked for comments; 0 = all; see ownposts.go
 	} `yaml:"bot"`
-	Keywords []string `yaml:"keywords"`
-	Posting  struct {
+	Keywords  []string        `yaml:"keywords"`
+	Relevance RelevanceConfig `yaml:"relevance"` // keyword weights and score thresholds; see relevance.go
+	Posting   struct {
 		Enabled  bool     `yaml:"enabled"`
 		Interval int      `yaml:"interval_minutes"`
 		Topics   []string `yaml:"topics"`
@@ -49,11 +56,80 @@ type Config struct {
 		StartDate string   `yaml:"hackathon_start_date"`
 		Tags      []string `yaml:"post_tags"`
 	} `yaml:"progress"`
+	RateLimits struct {
+		StateFile string          `yaml:"state_file"` // rolling 24h counts, kept across restarts
+		Vote      RateLimitConfig `yaml:"vote"`       // post and project votes
+		Comment   RateLimitConfig `yaml:"comment"`    // replies and engagement comments
+		Post      RateLimitConfig `yaml:"post"`       // new posts and progress updates
+		LLM       RateLimitConfig `yaml:"llm"`
+	} `yaml:"rate_limits"`
+	Retry struct {
+		MaxHeartbeatSeconds int         `yaml:"max_time_per_heartbeat_seconds"`
+		Read                RetryConfig `yaml:"read"`
+		Write               RetryConfig `yaml:"write"`
+		LLM                 RetryConfig `yaml:"llm"`
+	} `yaml:"retry"`
+	Schedule ScheduleConfig `yaml:"schedule"` // per-action timing in loop mode; see scheduler.go
+	State    struct {
+		Backend    string `yaml:"backend"`     // json | sqlite
+		SQLiteFile string `yaml:"sqlite_file"` // used by the sqlite backend
+	} `yaml:"state"`
 	Output struct {
 		LogFile        string `yaml:"log_file"`
 		TweetPattern   string `yaml:"tweet_file_pattern"`
 		SummaryPattern string `yaml:"summary_file_pattern"`
+		DryRunPattern  string `yaml:"dry_run_report_pattern"`
+		Dir            string `yaml:"dir"`           // tweets, summaries and dry-run reports go here; empty = working directory
+		DayStartsAt    string `yaml:"day_starts_at"` // HH:MM when a new tweet/summary file starts, in schedule.timezone
 	} `yaml:"output"`
+	Logging LoggingConfig `yaml:"logging"` // levels, format and rotation of output.log_file; see logging.go
+	Control ControlConfig `yaml:"control"` // local status/control API in loop mode; see control.go
+	Summary SummaryConfig `yaml:"summary"` // language and format of the round summary; see summary.go
+	Judge   JudgeConfig   `yaml:"judge"`   // LLM check before engage comments; see judge.go
+
+	Conversation ConversationConfig `yaml:"conversation"` // thread and history given to replies; see conversation.go
+}
+
+type RateLimitConfig struct {
+	IntervalSeconds float64 `yaml:"interval_seconds"` // one action per interval
+	Burst           int     `yaml:"burst"`
+	DailyCap        int     `yaml:"daily_cap"` // per rolling 24h, 0 = unlimited
+}
+
+func (rc RateLimitConfig) rule() ratelimit.Rule {
+	return ratelimit.Rule{
+		Interval: time.Duration(rc.IntervalSeconds * float64(time.Second)),
+		Burst:    rc.Burst,
+		DailyCap: rc.DailyCap,
+	}
+}
+
+// RetryConfig is the backoff setting for one endpoint class. Zero fields
+// fall back to 3 attempts, 500ms base delay and 30s max delay.
+type RetryConfig struct {
+	MaxAttempts     int `yaml:"max_attempts"` // 1 disables retries
+	BaseDelayMs     int `yaml:"base_delay_ms"`
+	MaxDelaySeconds int `yaml:"max_delay_seconds"`
+}
+
+func (rc RetryConfig) policy(budget *retry.Budget, onRetry func(int, time.Duration, error)) retry.Policy {
+	p := retry.Policy{
+		MaxAttempts: rc.MaxAttempts,
+		BaseDelay:   time.Duration(rc.BaseDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(rc.MaxDelaySeconds) * time.Second,
+		Budget:      budget,
+		OnRetry:     onRetry,
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	return p
 }
 
 type Prompts struct {
@@ -64,26 +140,12 @@ type Prompts struct {
 	NewPost       string `yaml:"new_post"`
 	Progress      string `yaml:"progress"`
 	FallbackReply string `yaml:"fallback_reply"`
+	Mission       string `yaml:"mission"` // what the agent is here for; given to the engagement judge
+	Judge         string `yaml:"judge"`   // engagement judge prompt; empty = built-in
 }
 
-var (
-	cfg     Config
-	prompts Prompts
-	// API Keys from env
-	ColosseumAPIKey string
-	ZhipuAPIKey     string
-)
-
 func init() {
 	loadEnvFile()
-	if key := os.Getenv("COLOSSEUM_API_KEY"); key != "" {
-		ColosseumAPIKey = key
-	}
-	if key := os.Getenv("ZHIPU_API_KEY"); key != "" {
-		ZhipuAPIKey = key
-	}
-	loadConfig()
-	loadPrompts()
 }
 
 func findConfigDir() string {
@@ -99,56 +161,79 @@ func findConfigDir() string {
 	return "config"
 }
 
-func loadConfig() {
-	configDir := findConfigDir()
-	data, err := os.ReadFile(filepath.Join(configDir, "config.yaml"))
-	if err != nil {
-		log.Printf("Warning: config.yaml not found, using defaults: %v", err)
-		setDefaultConfig()
-		return
-	}
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		log.Printf("Warning: failed to parse config.yaml: %v", err)
-		setDefaultConfig()
-	}
-}
-
-func loadPrompts() {
-	configDir := findConfigDir()
-	data, err := os.ReadFile(filepath.Join(configDir, "prompts.yaml"))
+// readPrompts parses prompts.yaml and checks that every template compiles.
+func readPrompts(path string) (Prompts, error) {
+	var p Prompts
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("Warning: prompts.yaml not found, using defaults: %v", err)
-		setDefaultPrompts()
-		return
+		return p, err
 	}
-	if err := yaml.Unmarshal(data, &prompts); err != nil {
-		log.Printf("Warning: failed to parse prompts.yaml: %v", err)
-		setDefaultPrompts()
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("%s: %w", path, err)
 	}
+	return p, validatePrompts(path, data, p)
 }
 
-func setDefaultConfig() {
+// defaultConfig is the bottom config layer; see layers.go.
+func defaultConfig() Config {
+	var cfg Config
 	cfg.API.BaseURL = "https://agents.colosseum.com/api"
 	cfg.API.ZhipuURL = "https://open.bigmodel.cn/api/paas/v4/chat/completions"
 	cfg.API.ZhipuModel = "glm-4-flash"
 	cfg.Agent.Name = "moltpost-agent"
 	cfg.Agent.PostID = 186
+	cfg.Agent.APIKeyEnv = "COLOSSEUM_API_KEY"
+	cfg.Agent.PromptsFile = "prompts.yaml"
+	cfg.Agent.StateFile = "nanopost_state.json"
 	cfg.Bot.DefaultInterval = 30
 	cfg.Bot.MaxEngagements = 2
-	cfg.Bot.RateLimit = 3
-	cfg.Bot.EngageRateLimit = 5
+	cfg.Bot.WatchOwnPosts = 20
+	cfg.Posting.Interval = 30
+	cfg.Schedule.Engage.Cron = "0 * * * *" // once an hour
+	cfg.Schedule.PostProgress.IntervalMinutes = 24 * 60
 	cfg.Keywords = []string{"human", "agent", "identity", "dialogue", "social", "encounter"}
+	cfg.Relevance.VoteThreshold = 1
+	cfg.Relevance.EngageThreshold = 1
+	cfg.Relevance.Embedding.Weight = 2
 	cfg.Progress.Tags = []string{"progress-update", "ai", "consumer"}
+	cfg.RateLimits.StateFile = "nanopost_ratelimit.json"
+	cfg.RateLimits.Vote.IntervalSeconds = 3
+	cfg.RateLimits.Comment.IntervalSeconds = 5
+	cfg.RateLimits.Post.IntervalSeconds = 60
+	cfg.RateLimits.LLM.IntervalSeconds = 1
+	cfg.Retry.MaxHeartbeatSeconds = 300
+	cfg.State.Backend = "json"
+	cfg.State.SQLiteFile = "nanopost.db"
 	cfg.Output.LogFile = "nanopost_log.txt"
 	cfg.Output.TweetPattern = "tweets_%s.md"
 	cfg.Output.SummaryPattern = "summary_%s.md"
+	cfg.Output.DryRunPattern = "dryrun_%s.md"
+	cfg.Output.DayStartsAt = "00:00"
+	cfg.Logging.Level = "info"
+	cfg.Logging.Format = "text"
+	cfg.Logging.Rotate = "size"
+	cfg.Logging.MaxSizeMB = 10
+	cfg.Logging.MaxFiles = 5
+	cfg.Control.Listen = "127.0.0.1:8642"
+	cfg.Control.TokenEnv = "NANOPOST_CONTROL_TOKEN"
+	cfg.Judge.MinConfidence = 0.6
+	cfg.Conversation.ContextTokens = 1500
+	cfg.Conversation.PastExchanges = 3
+	cfg.Conversation.Threading = "parent"
+	cfg.Summary.Language = "zh"
+	cfg.Summary.Format = "markdown"
+	return cfg
 }
 
-func setDefaultPrompts() {
-	prompts.System = "You are moltpost-agent, a philosophical AI assistant."
-	prompts.FallbackReply = "Thanks for your comment! -- moltpost-agent"
+func defaultPrompts() Prompts {
+	return Prompts{
+		System:        "You are moltpost-agent, a philosophical AI assistant.",
+		FallbackReply: "Thanks for your comment! -- moltpost-agent",
+	}
 }
 
+// loadEnvFile exports the KEY=value pairs of the first .env found. Variables
+// already set in the environment take precedence over the file.
 func loadEnvFile() {
 	paths := []string{".env"}
 	if exe, err := os.Executable(); err == nil {
@@ -166,11 +251,9 @@ func loadEnvFile() {
 				continue
 			}
 			if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
-				switch strings.TrimSpace(parts[0]) {
-				case "COLOSSEUM_API_KEY":
-					ColosseumAPIKey = strings.TrimSpace(parts[1])
-				case "ZHIPU_API_KEY":
-					ZhipuAPIKey = strings.TrimSpace(parts[1])
+				key := strings.TrimSpace(parts[0])
+				if _, set := os.LookupEnv(key); !set {
+					os.Setenv(key, strings.TrimSpace(parts[1]))
 				}
 			}
 		}
@@ -179,331 +262,350 @@ func loadEnvFile() {
 	}
 }
 
-// ==================== API Types ====================
-
-type AgentStatus struct {
-	Status    string `json:"status"`
-	Hackathon struct {
-		IsActive bool `json:"isActive"`
-	} `json:"hackathon"`
-	Engagement struct {
-		ForumPostCount     int    `json:"forumPostCount"`
-		RepliesOnYourPosts int    `json:"repliesOnYourPosts"`
-		ProjectStatus      string `json:"projectStatus"`
-	} `json:"engagement"`
-	NextSteps []string `json:"nextSteps"`
-}
-
-type Project struct {
-	Name         string `json:"name"`
-	AgentUpvotes int    `json:"agentUpvotes"`
-	HumanUpvotes int    `json:"humanUpvotes"`
-}
-
-type Post struct {
-	ID        int    `json:"id"`
-	AgentName string `json:"agentName"`
-	Title     string `json:"title"`
-	Body      string `json:"body"`
-}
-
-type Comment struct {
-	ID        int    `json:"id"`
-	AgentName string `json:"agentName"`
-	Body      string `json:"body"`
-}
-
-type LeaderboardProject struct {
-	ID           int    `json:"id"`
-	Name         string `json:"name"`
-	AgentUpvotes int    `json:"agentUpvotes"`
-	HumanUpvotes int    `json:"humanUpvotes"`
-}
-
-type ProjectInfo struct {
-	ID             int    `json:"id"`
-	Slug           string `json:"slug"`
-	Name           string `json:"name"`
-	Status         string `json:"status"`
-	OwnerAgentName string `json:"ownerAgentName"`
-}
-
-type ZhipuMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type ZhipuRequest struct {
-	Model    string         `json:"model"`
-	Messages []ZhipuMessage `json:"messages"`
-}
-
-type ZhipuResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
-}
-
 // ==================== Bot ====================
 
 type RoundStats struct {
-	RepliesCount, VotesCount, EngagementsCount, ProjectVotesCount int
-	RepliedTo, EngagedWith                                        []string
-	ProgressPosted, NewPostPosted                                 bool
-	LeaderboardRank                                               int
+	RepliesCount      int              `json:"replies"`
+	VotesCount        int              `json:"votes"`
+	EngagementsCount  int              `json:"engagements"`
+	ProjectVotesCount int              `json:"project_votes"`
+	RepliedTo         []string         `json:"replied_to,omitempty"`
+	EngagedWith       []string         `json:"engaged_with,omitempty"`
+	ProgressPosted    bool             `json:"progress_posted"`
+	NewPostPosted     bool             `json:"new_post_posted"`
+	LeaderboardRank   int              `json:"leaderboard_rank,omitempty"`
+	Mentions          int              `json:"mentions"`
+	Decisions         []EngageDecision `json:"decisions,omitempty"` // engagement judge answers
 }
 
 type Bot struct {
-	client                            *http.Client
-	processedComments, processedPosts map[int]bool
-	votedProjects                     map[int]bool
-	interactedAgents                  map[string]bool // Agents we've interacted with
-	lastProgressPost, lastNewPost     time.Time
-	logFile, tweetFile, summaryFile   *os.File
-	tweetCount                        int
-	roundStats                        RoundStats
-	topicIndex                        int
-	stateFile                         string
-}
-
-func NewBot() *Bot {
-	logFile, _ := os.OpenFile(cfg.Output.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	tweetFile, _ := os.OpenFile(fmt.Sprintf(cfg.Output.TweetPattern, time.Now().Format("2006-01-02")), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	summaryFile, _ := os.OpenFile(fmt.Sprintf(cfg.Output.SummaryPattern, time.Now().Format("2006-01-02")), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	client                        *http.Client
+	api                           *colosseum.Client
+	llm                           LLMProvider
+	retryBudget                   *retry.Budget // shared by API and LLM retries, reset each heartbeat
+	limiter                       *ratelimit.Limiter
+	seen                          *seenSet    // handled comments, posts, projects and agents held in memory
+	db                            *history.DB // sqlite backend; nil for json
+	lastProgressPost, lastNewPost time.Time
+	logOut                        *logfile.File               // output.log_file, rotated per logging config
+	logger                        atomic.Pointer[slog.Logger] // replaced on config reload
+	action                        string                      // action being run, logged with each line
+	post                          int                         // post being handled, logged with each line
+	tweetFile, summaryFile        datedFile                   // today's tweets_*.md and summary_*.md
+	summary                       *summaryRenderer            // renders roundStats into summaryFile
+	relevance                     *relevanceScorer            // scores posts for discover and engage
+	tweetCount                    int
+	roundStats                    RoundStats
+	topicIndex                    int
+	stateFile                     string
+	journal                       *os.File        // write-ahead journal, opened on first entry
+	dryRun                        bool            // record writes instead of sending them
+	rule                          string          // why the next write happens (dry-run report)
+	planned                       []PlannedAction // writes recorded during a dry run
+	watch                         configWatch
+	cfg                           Config            // this agent's effective config
+	name                          string            // agent.name, fixed for the bot's life; read by control API handlers
+	prompts                       Prompts           // this agent's prompts
+	overrides                     map[string]string // --key=value flags, re-applied on reload
+	logPrefix                     string            // "[name] " when several agents share stdout
+	limit                         int               // --limit: max items per action, 0 = defaults
+	quiet                         bool              // --json: log to the file only, keep stdout for the result
+	paused                        atomic.Bool       // write actions stopped via the control API
+	parentRejected                bool              // the API refused parentId; replies quote instead
+	trigger                       chan struct{}     // heartbeat requested via the control API
+	statusMu                      sync.Mutex        // guards status and stateInfo, read by the control API
+	status                        BotStatus
+	stateInfo                     StateInfo
+}
 
-	bot := &Bot{
-		client:            &http.Client{Timeout: 60 * time.Second},
-		processedComments: make(map[int]bool),
-		processedPosts:    make(map[int]bool),
-		votedProjects:     make(map[int]bool),
-		interactedAgents:  make(map[string]bool),
-		logFile:           logFile,
-		tweetFile:         tweetFile,
-		summaryFile:       summaryFile,
-		stateFile:         "nanopost_state.json",
-	}
-	bot.loadState()
-	return bot
-}
-
-// State persistence - 持久化已处理的评论和帖子ID
-type BotState struct {
-	ProcessedComments []int     `json:"processed_comments"`
-	ProcessedPosts    []int     `json:"processed_posts"`
-	VotedProjects     []int     `json:"voted_projects"`
-	InteractedAgents  []string  `json:"interacted_agents"`
-	LastProgressPost  time.Time `json:"last_progress_post"`
-	LastNewPost       time.Time `json:"last_new_post"`
-	TopicIndex        int       `json:"topic_index"`
-}
-
-func (b *Bot) loadState() {
-	data, err := os.ReadFile(b.stateFile)
+// NewBot builds the bot for one agent. Each bot owns its config, prompts,
+// files and clients, so several can run side by side.
+func NewBot(setup AgentSetup, configDir string, overrides map[string]string, multi bool) (*Bot, error) {
+	c := setup.Config
+	logOut, err := logfile.Open(c.Output.LogFile, c.Logging.policy())
 	if err != nil {
-		return // 文件不存在，使用空状态
+		return nil, fmt.Errorf("log file: %w", err)
 	}
-	var state BotState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return
+
+	bot := &Bot{
+		name:      c.Agent.Name,
+		client:    &http.Client{Timeout: 60 * time.Second},
+		seen:      newSeenSet(),
+		logOut:    logOut,
+		stateFile: c.Agent.StateFile,
+		overrides: overrides,
+		trigger:   make(chan struct{}, 1),
+	}
+	if multi {
+		bot.logPrefix = "[" + c.Agent.Name + "] "
+	}
+	if err := bot.configure(setup); err != nil {
+		return nil, err
+	}
+	bot.watch = newConfigWatch(configDir, c.Agent.PromptsFile, c.Summary.TemplateFile)
+	if c.State.Backend == "sqlite" {
+		if err := bot.openHistory(c.State.SQLiteFile); err != nil {
+			return nil, err
+		}
+		return bot, nil
+	}
+	if err := bot.loadState(); err != nil {
+		return nil, err
+	}
+	if err := bot.replayJournal(); err != nil {
+		return nil, err
 	}
-	for _, id := range state.ProcessedComments {
-		b.processedComments[id] = true
+	return bot, nil
+}
+
+// Close flushes and closes the bot's log, tweet and summary files and the
+// database.
+func (b *Bot) Close() {
+	if b.limiter != nil {
+		b.flushRateLimits()
+	}
+	if b.db != nil {
+		if err := b.db.Close(); err != nil {
+			b.logError("❌ Failed to close database: %v", err)
+		}
 	}
-	for _, id := range state.ProcessedPosts {
-		b.processedPosts[id] = true
+	for _, d := range []*datedFile{&b.tweetFile, &b.summaryFile} {
+		if err := d.Close(); err != nil {
+			b.logError("❌ Failed to flush %s: %v", d.name, err)
+		}
 	}
-	for _, id := range state.VotedProjects {
-		b.votedProjects[id] = true
+	if b.journal != nil {
+		if err := b.journal.Sync(); err != nil {
+			b.logError("❌ Failed to flush journal: %v", err)
+		}
+		b.journal.Close()
 	}
-	for _, name := range state.InteractedAgents {
-		b.interactedAgents[name] = true
+	if b.logOut != nil {
+		b.logOut.Sync()
+		b.logOut.Close()
 	}
-	b.lastProgressPost = state.LastProgressPost
-	b.lastNewPost = state.LastNewPost
-	b.topicIndex = state.TopicIndex
 }
 
-func (b *Bot) saveState() {
-	var comments, posts, projects []int
-	var agents []string
-	for id := range b.processedComments {
-		comments = append(comments, id)
+// configure builds the config-dependent parts of the bot (API client, LLM
+// provider, retry policies, rate limiter). Nothing is replaced unless every
+// part builds, so a bad reload leaves the running bot untouched.
+func (b *Bot) configure(setup AgentSetup) error {
+	c := setup.Config
+	if setup.APIKey == "" {
+		return fmt.Errorf("%s required for agent %s", c.Agent.APIKeyEnv, c.Agent.Name)
+	}
+	llm, err := newLLMProvider(c, b.client)
+	if err != nil {
+		return err
 	}
-	for id := range b.processedPosts {
-		posts = append(posts, id)
+	if b.limiter != nil {
+		b.flushRateLimits() // the new limiter reads the counts from the file
 	}
-	for id := range b.votedProjects {
-		projects = append(projects, id)
+	limiter, err := ratelimit.New(map[string]ratelimit.Rule{
+		"vote":    c.RateLimits.Vote.rule(),
+		"comment": c.RateLimits.Comment.rule(),
+		"post":    c.RateLimits.Post.rule(),
+		"llm":     c.RateLimits.LLM.rule(),
+	}, c.RateLimits.StateFile)
+	if err != nil {
+		return err
+	}
+	if b.dryRun {
+		limiter.Simulate()
+	}
+	budget := retry.NewBudget(time.Duration(c.Retry.MaxHeartbeatSeconds) * time.Second)
+	api := colosseum.NewClient(c.API.BaseURL, setup.APIKey, b.client)
+	api.Read = c.Retry.Read.policy(budget, b.logRetry("API read"))
+	api.Write = c.Retry.Write.policy(budget, b.logRetry("API write"))
+	api.Limiter = limiter
+	api.Observe = observeAPI(c.Agent.Name)
+	summary, err := newSummaryRenderer(c.Summary, setup.Summary)
+	if err != nil {
+		return err
 	}
-	for name := range b.interactedAgents {
-		agents = append(agents, name)
+	var embed *embedder
+	if c.Relevance.Embedding.Enabled {
+		if embed, err = newEmbedder(c, b.client, limiter, c.Retry.LLM.policy(budget, b.logRetry("Embeddings"))); err != nil {
+			return err
+		}
 	}
-	state := BotState{
-		ProcessedComments: comments,
-		ProcessedPosts:    posts,
-		VotedProjects:     projects,
-		InteractedAgents:  agents,
-		LastProgressPost:  b.lastProgressPost,
-		LastNewPost:       b.lastNewPost,
-		TopicIndex:        b.topicIndex,
+
+	b.cfg, b.prompts = c, setup.Prompts
+	b.tweetFile.path = c.datedPath(c.Output.TweetPattern)
+	b.summaryFile.path = c.datedPath(c.summaryPattern())
+	b.summary = summary
+	b.relevance = newRelevanceScorer(c, embed)
+	b.logOut.SetPolicy(c.Logging.policy())
+	b.logger.Store(b.newLogger(c, b.logOut))
+	b.api = api
+	b.parentRejected = false // the new config may point at another API, or retry threading
+	b.retryBudget = budget
+	b.limiter = limiter
+	b.llm = &limitedProvider{
+		LLMProvider: &retryingProvider{
+			LLMProvider: &meteredProvider{LLMProvider: llm, agent: c.Agent.Name},
+			policy:      c.Retry.LLM.policy(budget, b.logRetry("LLM")),
+		},
+		limiter: limiter,
+	}
+	return nil
+}
+
+func (b *Bot) logRetry(class string) func(int, time.Duration, error) {
+	return func(attempt int, delay time.Duration, err error) {
+		b.logWarn("🔁 %s attempt %d failed, retrying in %v: %v", class, attempt, delay.Round(time.Millisecond), err)
+	}
+}
+
+// flushRateLimits saves the daily counts if saving them after an action
+// failed, and logs why.
+func (b *Bot) flushRateLimits() {
+	if err := b.limiter.Flush(); err != nil {
+		b.logError("❌ Failed to save rate limits: %v", err)
 	}
-	data, _ := json.MarshalIndent(state, "", "  ")
-	os.WriteFile(b.stateFile, data, 0644)
 }
 
-func (b *Bot) log(format string, args ...interface{}) {
-	msg := fmt.Sprintf("[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
-	fmt.Print(msg)
-	if b.logFile != nil {
-		b.logFile.WriteString(msg)
+// capReached reports (and logs) whether kind has used its 24h allowance,
+// so callers can stop before spending an LLM call on content they can't send.
+func (b *Bot) capReached(kind string) bool {
+	used, limit := b.limiter.Used(kind)
+	if limit > 0 && used >= limit {
+		b.logWarn("⛔ Daily %s cap reached (%d/%d in 24h)", kind, used, limit)
+		return true
 	}
+	return false
 }
 
 func (b *Bot) resetRoundStats() { b.roundStats = RoundStats{} }
 
 func (b *Bot) saveRoundSummary() {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("\n---\n\n## 🕐 %s\n\n", time.Now().Format("15:04:05")))
-	sb.WriteString("| 指标 | 数量 | 详情 |\n|------|------|------|\n")
-	sb.WriteString(fmt.Sprintf("| 💬 回复 | %d | %s |\n", b.roundStats.RepliesCount, strings.Join(b.roundStats.RepliedTo, ", ")))
-	sb.WriteString(fmt.Sprintf("| 👍 帖子投票 | %d | - |\n", b.roundStats.VotesCount))
-	sb.WriteString(fmt.Sprintf("| 🗳️ 项目投票 | %d | - |\n", b.roundStats.ProjectVotesCount))
-	sb.WriteString(fmt.Sprintf("| 🤝 互动 | %d | %s |\n", b.roundStats.EngagementsCount, strings.Join(b.roundStats.EngagedWith, ", ")))
-	if b.roundStats.NewPostPosted {
-		sb.WriteString("| 📮 新帖 | ✅ | 已发布 |\n")
+	data := SummaryData{Time: time.Now(), Agent: b.cfg.Agent.Name, Stats: b.roundStats}
+	text, err := b.summary.render(data)
+	if err != nil {
+		b.logError("❌ Failed to render summary: %v", err)
+		return
 	}
-	if b.roundStats.ProgressPosted {
-		sb.WriteString("| 📝 进度 | ✅ | 已发布 |\n")
+	// A new html file starts with the document head, so it opens as a page.
+	if empty, err := b.summaryFile.empty(data.Time); err != nil {
+		b.logError("❌ Failed to save summary: %v", err)
+		return
+	} else if empty {
+		head, err := b.summary.head(data)
+		if err != nil {
+			b.logError("❌ Failed to render summary: %v", err)
+			return
+		}
+		text = head + text
 	}
-	if b.roundStats.LeaderboardRank > 0 {
-		sb.WriteString(fmt.Sprintf("| 🏆 排名 | #%d | - |\n", b.roundStats.LeaderboardRank))
+	if _, err := b.summaryFile.WriteString(text); err != nil {
+		b.logError("❌ Failed to save summary: %v", err)
+		return
 	}
-	b.summaryFile.WriteString(sb.String())
-	b.log("📋 中文总结已保存")
+	b.log("📋 Round summary saved (%s, %s)", b.cfg.Summary.Language, b.cfg.Summary.Format)
 }
 
 func (b *Bot) saveTweet(tweetType, content string) {
+	if b.dryRun {
+		b.because("%s tweet", tweetType)
+		b.plan("tweet", 0, content)
+		return
+	}
+	b.record(history.Entry{Kind: "tweet", Sent: content, Detail: tweetType})
+	if opened, err := b.tweetFile.roll(time.Now()); err != nil {
+		b.logError("❌ Failed to save %s tweet: %v", tweetType, err)
+		return
+	} else if opened {
+		b.tweetCount = 0 // numbering starts over in each day's file
+	}
 	b.tweetCount++
-	b.tweetFile.WriteString(fmt.Sprintf("\n---\n\n### Tweet #%d (%s) - %s\n\n%s\n\n---\n", b.tweetCount, time.Now().Format("15:04"), tweetType, content))
+	if _, err := b.tweetFile.WriteString(fmt.Sprintf("\n---\n\n### Tweet #%d (%s) - %s\n\n%s\n\n---\n", b.tweetCount, time.Now().Format("15:04"), tweetType, content)); err != nil {
+		b.logError("❌ Failed to save %s tweet: %v", tweetType, err)
+		return
+	}
 	b.log("📝 Tweet saved: %s", tweetType)
 }
 
-// ==================== HTTP & AI ====================
-
-func (b *Bot) request(method, endpoint string, body interface{}) ([]byte, error) {
-	var reqBody io.Reader
-	if body != nil {
-		data, _ := json.Marshal(body)
-		reqBody = bytes.NewBuffer(data)
-	}
-	req, _ := http.NewRequest(method, cfg.API.BaseURL+endpoint, reqBody)
-	req.Header.Set("Authorization", "Bearer "+ColosseumAPIKey)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := b.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
-}
+// ==================== AI ====================
 
-func (b *Bot) callAI(userPrompt string) (string, error) {
-	data, _ := json.Marshal(ZhipuRequest{
-		Model:    cfg.API.ZhipuModel,
-		Messages: []ZhipuMessage{{Role: "system", Content: prompts.System}, {Role: "user", Content: userPrompt}},
-	})
-	req, _ := http.NewRequest("POST", cfg.API.ZhipuURL, bytes.NewBuffer(data))
-	req.Header.Set("Authorization", "Bearer "+ZhipuAPIKey)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := b.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var r ZhipuResponse
-	json.Unmarshal(body, &r)
-	if len(r.Choices) == 0 {
-		return "", fmt.Errorf("no response")
-	}
-	return r.Choices[0].Message.Content, nil
+func (b *Bot) callAI(ctx context.Context, userPrompt string) (string, error) {
+	return b.llm.Chat(ctx, []ChatMessage{{Role: "system", Content: b.prompts.System}, {Role: "user", Content: userPrompt}})
 }
 
 func (b *Bot) renderPrompt(tmplStr string, data interface{}) string {
 	tmpl, err := template.New("").Parse(tmplStr)
 	if err != nil {
+		b.logError("❌ Prompt template does not parse, sending it unrendered: %v", err)
 		return tmplStr
 	}
 	var buf bytes.Buffer
-	tmpl.Execute(&buf, data)
+	if err := tmpl.Execute(&buf, data); err != nil {
+		b.logError("❌ Prompt template failed: %v", err)
+	}
 	return buf.String()
 }
 
-func (b *Bot) generateTweet(tweetType, context string) string {
-	prompt := b.renderPrompt(prompts.Tweet, map[string]string{"Type": tweetType, "Context": context})
-	tweet, err := b.callAI(prompt)
-	if err != nil || len(tweet) > 280 {
-		if len(tweet) > 280 {
-			tweet = tweet[:277] + "..."
-		}
+func (b *Bot) generateTweet(ctx context.Context, tweetType, context string) string {
+	prompt := b.renderPrompt(b.prompts.Tweet, map[string]string{"Type": tweetType, "Context": context})
+	tweet, err := b.callAI(ctx, prompt)
+	if err != nil {
+		b.logWarn("⚠️ No %s tweet, AI error: %v", tweetType, err)
+		return ""
+	}
+	if len(tweet) > 280 {
+		tweet = tweet[:277] + "..."
 	}
 	return strings.TrimSpace(tweet)
 }
 
-func (b *Bot) generateReply(agentName, body string) string {
-	prompt := b.renderPrompt(prompts.Reply, map[string]string{"AgentName": agentName, "CommentBody": body, "PostContext": ""})
-	reply, err := b.callAI(prompt)
+func (b *Bot) generateComment(ctx context.Context, post colosseum.Post) string {
+	prompt := b.renderPrompt(b.prompts.Comment, map[string]string{"Title": post.Title, "AgentName": post.AgentName, "Body": truncate(post.Body, 500)})
+	comment, err := b.callAI(ctx, prompt)
 	if err != nil {
-		return b.renderPrompt(prompts.FallbackReply, map[string]string{"AgentName": agentName})
+		b.logError("❌ Failed to generate comment: %v", err)
 	}
-	return reply
-}
-
-func (b *Bot) generateComment(post Post) string {
-	prompt := b.renderPrompt(prompts.Comment, map[string]string{"Title": post.Title, "AgentName": post.AgentName, "Body": truncate(post.Body, 500)})
-	comment, _ := b.callAI(prompt)
 	return comment
 }
 
-func (b *Bot) generateProgress() string {
-	progress, _ := b.callAI(prompts.Progress)
+func (b *Bot) generateProgress(ctx context.Context) string {
+	progress, err := b.callAI(ctx, b.prompts.Progress)
+	if err != nil {
+		b.logError("❌ Failed to generate progress update: %v", err)
+	}
 	return progress
 }
 
-func (b *Bot) generateNewPost() (title, body string, tags []string) {
+func (b *Bot) generateNewPost(ctx context.Context) (title, body string, tags []string) {
 	// 从话题池中选择一个话题
-	if len(cfg.Posting.Topics) == 0 {
-		b.log("⚠️ No topics configured")
+	if len(b.cfg.Posting.Topics) == 0 {
+		b.logWarn("⚠️ No topics configured")
 		return "", "", nil
 	}
-	topic := cfg.Posting.Topics[b.topicIndex%len(cfg.Posting.Topics)]
+	topic := b.cfg.Posting.Topics[b.topicIndex%len(b.cfg.Posting.Topics)]
 	b.topicIndex++
 	b.log("📝 Topic: %s", topic)
 
 	// 检查 prompt 是否存在
-	if prompts.NewPost == "" {
-		b.log("⚠️ NewPost prompt is empty in prompts.yaml!")
+	if b.prompts.NewPost == "" {
+		b.logWarn("⚠️ NewPost prompt is empty in prompts.yaml!")
 		return "", "", nil
 	}
 
-	prompt := b.renderPrompt(prompts.NewPost, map[string]string{"Topic": topic})
+	prompt := b.renderPrompt(b.prompts.NewPost, map[string]string{"Topic": topic})
 	if prompt == "" {
-		b.log("⚠️ Rendered prompt is empty")
+		b.logWarn("⚠️ Rendered prompt is empty")
 		return "", "", nil
 	}
 
-	response, err := b.callAI(prompt)
+	response, err := b.callAI(ctx, prompt)
 	if err != nil {
-		b.log("⚠️ AI error: %v", err)
+		b.logError("❌ AI error: %v", err)
 		return "", "", nil
 	}
 	if response == "" {
-		b.log("⚠️ AI returned empty response")
+		b.logWarn("⚠️ AI returned empty response")
 		return "", "", nil
 	}
-	b.log("📝 AI response length: %d", len(response))
+	b.logDebug("📝 AI response length: %d", len(response))
 
 	// 解析响应 - 更健壮的解析
 	lines := strings.Split(response, "\n")
@@ -589,7 +691,7 @@ func (b *Bot) generateNewPost() (title, body string, tags []string) {
 		}
 	}
 
-	b.log("📝 Parsed - Title: %s, Body len: %d, Tags: %v", title, len(body), tags)
+	b.logDebug("📝 Parsed - Title: %s, Body len: %d, Tags: %v", title, len(body), tags)
 
 	// 确保至少有一个标签
 	if len(tags) == 0 {
@@ -608,193 +710,250 @@ func truncate(s string, n int) string {
 
 // ==================== API Calls ====================
 
-func (b *Bot) GetStatus() (*AgentStatus, error) {
-	data, err := b.request("GET", "/agents/status", nil)
-	if err != nil {
-		return nil, err
-	}
-	var s AgentStatus
-	json.Unmarshal(data, &s)
-	return &s, nil
+func (b *Bot) GetStatus(ctx context.Context) (*colosseum.AgentStatus, error) {
+	return b.api.Status(ctx)
 }
 
-func (b *Bot) GetProject() (*Project, error) {
-	data, err := b.request("GET", "/my-project", nil)
-	if err != nil {
-		return nil, err
-	}
-	var p Project
-	json.Unmarshal(data, &p)
-	return &p, nil
+func (b *Bot) GetProject(ctx context.Context) (*colosseum.Project, error) {
+	return b.api.MyProject(ctx)
 }
 
-func (b *Bot) GetPosts(sort string, limit int) ([]Post, error) {
-	data, err := b.request("GET", fmt.Sprintf("/forum/posts?sort=%s&limit=%d", sort, limit), nil)
-	if err != nil {
-		return nil, err
-	}
-	var r struct{ Posts []Post }
-	json.Unmarshal(data, &r)
-	return r.Posts, nil
+func (b *Bot) GetPosts(ctx context.Context, sort string, limit int) ([]colosseum.Post, error) {
+	return b.api.Posts(ctx, sort, limit)
 }
 
-func (b *Bot) GetComments(postID int) ([]Comment, error) {
-	data, err := b.request("GET", fmt.Sprintf("/forum/posts/%d/comments?sort=new&limit=50", postID), nil)
-	if err != nil {
-		return nil, err
-	}
-	var r struct{ Comments []Comment }
-	json.Unmarshal(data, &r)
-	return r.Comments, nil
+func (b *Bot) GetPost(ctx context.Context, postID int) (*colosseum.Post, error) {
+	return b.api.Post(ctx, postID)
 }
 
-func (b *Bot) GetLeaderboard() ([]LeaderboardProject, error) {
-	data, _ := b.request("GET", "/hackathons/active", nil)
-	var h struct{ ID int }
-	json.Unmarshal(data, &h)
-	data, err := b.request("GET", fmt.Sprintf("/hackathons/%d/leaderboard?limit=10", h.ID), nil)
+func (b *Bot) GetLeaderboard(ctx context.Context, limit int) ([]colosseum.LeaderboardProject, error) {
+	h, err := b.api.ActiveHackathon(ctx)
 	if err != nil {
 		return nil, err
 	}
-	var r struct{ Projects []LeaderboardProject }
-	json.Unmarshal(data, &r)
-	return r.Projects, nil
+	return b.api.Leaderboard(ctx, h.ID, limit)
 }
 
-func (b *Bot) Vote(postID int) error {
-	_, err := b.request("POST", fmt.Sprintf("/forum/posts/%d/vote", postID), map[string]int{"value": 1})
-	return err
+func (b *Bot) GetProjects(ctx context.Context, includeDrafts bool) ([]colosseum.ProjectInfo, error) {
+	return b.api.Projects(ctx, includeDrafts)
 }
 
-func (b *Bot) Comment(postID int, body string) error {
-	_, err := b.request("POST", fmt.Sprintf("/forum/posts/%d/comments", postID), map[string]string{"body": body})
-	return err
+func (b *Bot) Vote(ctx context.Context, postID int) error {
+	if b.plan("vote", postID, "") {
+		return nil
+	}
+	if b.paused.Load() {
+		return errPaused
+	}
+	return b.api.VotePost(ctx, postID)
 }
 
-func (b *Bot) CreatePost(title, body string, tags []string) error {
-	_, err := b.request("POST", "/forum/posts", map[string]interface{}{"title": title, "body": body, "tags": tags})
-	return err
+// Comment posts body on a post, under comment parentID if it isn't 0. It
+// returns our comment's ID, or 0 in dry runs and when the API doesn't say.
+func (b *Bot) Comment(ctx context.Context, postID, parentID int, body string) (int, error) {
+	if b.plan("comment", postID, body) {
+		return 0, nil
+	}
+	if b.paused.Load() {
+		return 0, errPaused
+	}
+	c, err := b.api.CreateComment(ctx, postID, parentID, body)
+	if err != nil {
+		return 0, err
+	}
+	return c.ID, nil
 }
 
-func (b *Bot) GetProjects(includeDrafts bool) ([]ProjectInfo, error) {
-	url := "/projects/current"
-	if includeDrafts {
-		url = "/projects?includeDrafts=true"
+// CreatePost returns the new post's ID, or 0 in dry runs and when the API
+// doesn't say.
+func (b *Bot) CreatePost(ctx context.Context, title, body string, tags []string) (int, error) {
+	if b.plan("post", 0, fmt.Sprintf("### %s\n\n%s\n\nTags: %s", title, body, strings.Join(tags, ", "))) {
+		return 0, nil
 	}
-	data, err := b.request("GET", url, nil)
+	if b.paused.Load() {
+		return 0, errPaused
+	}
+	p, err := b.api.CreatePost(ctx, title, body, tags)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	var r struct{ Projects []ProjectInfo }
-	json.Unmarshal(data, &r)
-	return r.Projects, nil
+	return p.ID, nil
 }
 
-func (b *Bot) VoteProject(projectID int) error {
-	_, err := b.request("POST", fmt.Sprintf("/projects/%d/vote", projectID), nil)
-	return err
+func (b *Bot) VoteProject(ctx context.Context, projectID int) error {
+	if b.plan("vote-project", projectID, "") {
+		return nil
+	}
+	if b.paused.Load() {
+		return errPaused
+	}
+	return b.api.VoteProject(ctx, projectID)
 }
 
 // ==================== Actions ====================
 
-func (b *Bot) CheckComments() {
+func (b *Bot) CheckComments(ctx context.Context) {
 	b.log("=== 📩 Checking for new comments ===")
-	comments, err := b.GetComments(cfg.Agent.PostID)
+	defer b.focus(0)
+	posts := b.watchedPosts()
+	b.logDebug("Watching %d posts: %v", len(posts), posts)
+	for _, postID := range posts {
+		if !b.replyToComments(ctx, postID) {
+			return
+		}
+	}
+}
+
+// replyToComments answers the new comments on one post. It returns false
+// when the round should stop: on shutdown or once the reply limit or the
+// daily comment cap is reached.
+func (b *Bot) replyToComments(ctx context.Context, postID int) bool {
+	b.focus(postID)
+	comments, err := b.GetComments(ctx, postID)
 	if err != nil {
-		return
+		b.logError("❌ Failed to get comments on post #%d: %v", postID, err)
+		return ctx.Err() == nil
 	}
-	for _, c := range comments {
-		if c.AgentName == cfg.Agent.Name || b.processedComments[c.ID] {
+	var post *colosseum.Post // fetched with the first new comment
+	for i, c := range comments {
+		if ctx.Err() != nil {
+			return false
+		}
+		if c.AgentName == b.cfg.Agent.Name || b.done("comment", c.ID, "") {
 			continue
 		}
+		if b.limitReached(b.roundStats.RepliesCount) || b.capReached("comment") {
+			return false
+		}
 		b.log("📩 New comment from @%s: %s", c.AgentName, truncate(c.Body, 80))
-		reply := b.generateReply(c.AgentName, c.Body)
-		if err := b.Comment(cfg.Agent.PostID, reply); err == nil {
+		if post == nil {
+			if post, err = b.GetPost(ctx, postID); err != nil {
+				b.logWarn("⚠️ Failed to get post #%d, replying without it: %v", postID, err)
+				post = &colosseum.Post{ID: postID}
+			}
+		}
+		reply := b.generateReply(ctx, threadFor(post, comments, i), c)
+		if ctx.Err() != nil {
+			return false // don't send the fallback reply on shutdown
+		}
+		b.because("new comment #%d from @%s on post #%d", c.ID, c.AgentName, postID)
+		if id, err := b.sendReply(ctx, postID, c, reply); err != nil {
+			b.logError("❌ Failed to reply to @%s: %v", c.AgentName, err) // 不标记为已处理，下轮重试
+		} else {
 			b.log("✅ Replied to @%s", c.AgentName)
+			b.record(history.Entry{Kind: "reply", PostID: postID, TargetID: c.ID, CommentID: id, Agent: c.AgentName, Received: c.Body, Sent: reply})
+			b.remember("comment", c.ID, "")
+			b.rememberReply(c.ID, id)
+			b.remember("agent", 0, c.AgentName) // Track interaction
 			b.roundStats.RepliesCount++
 			b.roundStats.RepliedTo = append(b.roundStats.RepliedTo, "@"+c.AgentName)
-			b.interactedAgents[c.AgentName] = true // Track interaction
-			if tweet := b.generateTweet("Reply", fmt.Sprintf("Replied to @%s", c.AgentName)); tweet != "" {
+			if tweet := b.generateTweet(ctx, "Reply", fmt.Sprintf("Replied to @%s", c.AgentName)); tweet != "" {
 				b.saveTweet("Reply", tweet)
 			}
 		}
-		b.processedComments[c.ID] = true
-		time.Sleep(time.Duration(cfg.Bot.RateLimit) * time.Second)
 	}
+	return true
 }
 
-func (b *Bot) DiscoverAndVote() {
+func (b *Bot) DiscoverAndVote(ctx context.Context) {
 	b.log("=== 🔍 Discovering relevant projects ===")
-	posts, err := b.GetPosts("new", 20)
+	posts, err := b.GetPosts(ctx, "new", 20)
 	if err != nil {
+		b.logError("❌ Failed to get posts: %v", err)
 		return
 	}
 	voted := 0
 	for _, p := range posts {
-		if p.AgentName == cfg.Agent.Name || b.processedPosts[p.ID] {
+		if ctx.Err() != nil || b.limitReached(voted) {
+			break
+		}
+		if p.AgentName == b.cfg.Agent.Name || b.done("post", p.ID, "") {
 			continue
 		}
-		body := strings.ToLower(p.Body + " " + p.Title)
-		for _, kw := range cfg.Keywords {
-			if strings.Contains(body, kw) {
-				b.log("🔍 Found relevant: %s by @%s", truncate(p.Title, 50), p.AgentName)
-				if b.Vote(p.ID) == nil {
-					b.log("✅ Voted for post #%d", p.ID)
-					voted++
-				}
-				b.processedPosts[p.ID] = true
-				break
-			}
+		b.focus(p.ID)
+		r, threshold := b.score(ctx, p), b.cfg.Relevance.VoteThreshold
+		if r.score < threshold {
+			b.logDebug("Skipping %s by @%s: score %s below %s", truncate(p.Title, 50), p.AgentName, r, formatScore(threshold))
+			continue
+		}
+		b.log("🔍 Found relevant: %s by @%s, score %s", truncate(p.Title, 50), p.AgentName, r)
+		b.because("relevance score %s ≥ %s", r, formatScore(threshold))
+		if err := b.Vote(ctx, p.ID); err == nil {
+			b.log("✅ Voted for post #%d", p.ID)
+			b.record(history.Entry{Kind: "vote", PostID: p.ID, Agent: p.AgentName, Received: p.Title})
+			voted++
+		} else if errors.Is(err, ratelimit.ErrDailyCap) {
+			b.logWarn("⛔ %v", err)
+			break
+		} else if colosseum.IsStatus(err, http.StatusConflict) { // 409 = already voted
+			b.logDebug("Already voted for post #%d", p.ID)
+		} else {
+			b.logError("❌ Failed to vote for post #%d: %v", p.ID, err)
+			continue
 		}
+		b.remember("post", p.ID, "")
 	}
+	b.focus(0)
 	b.log("Voted for %d new posts", voted)
 	b.roundStats.VotesCount = voted
 	if voted > 0 {
-		if tweet := b.generateTweet("Voting", fmt.Sprintf("Supported %d projects", voted)); tweet != "" {
+		if tweet := b.generateTweet(ctx, "Voting", fmt.Sprintf("Supported %d projects", voted)); tweet != "" {
 			b.saveTweet("Voting", tweet)
 		}
 	}
 }
 
-func (b *Bot) VoteProjects() {
+func (b *Bot) VoteProjects(ctx context.Context) {
 	b.log("=== 🗳️ Voting for other projects ===")
-	projects, err := b.GetProjects(true) // Include drafts
+	projects, err := b.GetProjects(ctx, true) // Include drafts
 	if err != nil {
-		b.log("❌ Failed to get projects: %v", err)
+		b.logError("❌ Failed to get projects: %v", err)
 		return
 	}
 
 	// Separate priority projects (interacted agents) from others
-	var priorityProjects, otherProjects []ProjectInfo
+	var priorityProjects, otherProjects []colosseum.ProjectInfo
 	for _, p := range projects {
-		if p.ID == cfg.Agent.ProjectID || b.votedProjects[p.ID] {
+		if p.ID == b.cfg.Agent.ProjectID || b.done("project", p.ID, "") {
 			continue
 		}
-		if b.interactedAgents[p.OwnerAgentName] {
+		if b.done("agent", 0, p.OwnerAgentName) {
 			priorityProjects = append(priorityProjects, p)
 		} else {
 			otherProjects = append(otherProjects, p)
 		}
 	}
 
-	voted := 0
+	voted, capped := 0, false
 	// Vote for priority projects first (agents we've interacted with)
 	for _, p := range priorityProjects {
-		if err := b.VoteProject(p.ID); err == nil {
+		if capped = ctx.Err() != nil || b.limitReached(voted); capped {
+			break
+		}
+		b.because("priority: interacted with owner @%s", p.OwnerAgentName)
+		if err := b.VoteProject(ctx, p.ID); err == nil {
 			b.log("⭐ PRIORITY voted for project: %s by @%s (ID: %d)", p.Name, p.OwnerAgentName, p.ID)
+			b.record(history.Entry{Kind: "project_vote", TargetID: p.ID, Agent: p.OwnerAgentName, Received: p.Name})
 			voted++
-			b.votedProjects[p.ID] = true
-			time.Sleep(time.Duration(cfg.Bot.RateLimit) * time.Second)
+			b.remember("project", p.ID, "")
+		} else if capped = b.markProjectVoteFailed(p, err); capped {
+			break
 		}
 	}
 
 	// Then vote for other projects
 	for _, p := range otherProjects {
-		if err := b.VoteProject(p.ID); err == nil {
+		if capped || ctx.Err() != nil || b.limitReached(voted) {
+			break
+		}
+		b.because("project not voted yet")
+		if err := b.VoteProject(ctx, p.ID); err == nil {
 			b.log("✅ Voted for project: %s (ID: %d)", p.Name, p.ID)
+			b.record(history.Entry{Kind: "project_vote", TargetID: p.ID, Agent: p.OwnerAgentName, Received: p.Name})
 			voted++
-			b.votedProjects[p.ID] = true
-			time.Sleep(time.Duration(cfg.Bot.RateLimit) * time.Second)
+			b.remember("project", p.ID, "")
+		} else if capped = b.markProjectVoteFailed(p, err); capped {
+			break
 		}
 	}
 
@@ -802,116 +961,231 @@ func (b *Bot) VoteProjects() {
 	b.roundStats.ProjectVotesCount = voted
 }
 
-func (b *Bot) EngageWithPosts() {
+// markProjectVoteFailed logs a failed project vote. A 409 means we already
+// voted, so the project is remembered instead of being retried every round.
+// It returns true when voting has to stop for this round (daily cap).
+func (b *Bot) markProjectVoteFailed(p colosseum.ProjectInfo, err error) bool {
+	switch {
+	case errors.Is(err, ratelimit.ErrDailyCap):
+		b.logWarn("⛔ %v", err)
+		return true
+	case colosseum.IsStatus(err, http.StatusConflict):
+		b.logDebug("Already voted for project %s (ID: %d)", p.Name, p.ID)
+		b.remember("project", p.ID, "")
+	default:
+		b.logError("❌ Failed to vote for project %s (ID: %d): %v", p.Name, p.ID, err)
+	}
+	return false
+}
+
+func (b *Bot) EngageWithPosts(ctx context.Context) {
 	b.log("=== 💬 Engaging with other posts ===")
-	posts, err := b.GetPosts("hot", 10)
+	posts, err := b.GetPosts(ctx, "hot", 10)
 	if err != nil {
+		b.logError("❌ Failed to get posts: %v", err)
 		return
 	}
-	engaged := 0
+	engaged, maxEngaged := 0, b.cfg.Bot.MaxEngagements
+	if b.limit > 0 {
+		maxEngaged = b.limit
+	}
 	for _, p := range posts {
-		if p.AgentName == cfg.Agent.Name || b.processedPosts[p.ID] || engaged >= cfg.Bot.MaxEngagements {
+		if ctx.Err() != nil {
+			return
+		}
+		if p.AgentName == b.cfg.Agent.Name || b.done("post", p.ID, "") || b.done("declined", p.ID, "") || engaged >= maxEngaged {
 			continue
 		}
-		body := strings.ToLower(p.Body)
-		for _, kw := range cfg.Keywords[:4] { // Use first 4 keywords
-			if strings.Contains(body, kw) {
-				b.log("💬 Engaging with: %s by @%s", truncate(p.Title, 40), p.AgentName)
-				if comment := b.generateComment(p); comment != "" {
-					if b.Comment(p.ID, comment) == nil {
-						b.log("✅ Commented on post #%d", p.ID)
-						engaged++
-						b.roundStats.EngagementsCount++
-						b.roundStats.EngagedWith = append(b.roundStats.EngagedWith, "@"+p.AgentName)
-						b.interactedAgents[p.AgentName] = true // Track interaction
-						if tweet := b.generateTweet("Engagement", fmt.Sprintf("Connected with @%s", p.AgentName)); tweet != "" {
-							b.saveTweet("Engagement", tweet)
-						}
-					}
+		if b.capReached("comment") {
+			return
+		}
+		b.focus(p.ID)
+		r, threshold := b.score(ctx, p), b.cfg.Relevance.EngageThreshold
+		if r.score < threshold {
+			b.logDebug("Not engaging with %s by @%s: score %s below %s", truncate(p.Title, 40), p.AgentName, r, formatScore(threshold))
+			continue
+		}
+		b.because("relevance score %s ≥ %s", r, formatScore(threshold))
+		if b.cfg.Judge.Enabled {
+			d, err := b.judgeEngagement(ctx, p)
+			if err != nil {
+				if ctx.Err() == nil {
+					b.logWarn("⚠️ Engagement judge failed for post #%d, not engaging this round: %v", p.ID, err)
 				}
-				b.processedPosts[p.ID] = true
-				time.Sleep(time.Duration(cfg.Bot.EngageRateLimit) * time.Second)
-				break
+				continue
+			}
+			if d.Engage && d.Confidence < b.cfg.Judge.MinConfidence {
+				d.Engage = false
+				d.Reason = fmt.Sprintf("confidence below %.2f; %s", b.cfg.Judge.MinConfidence, d.Reason)
+			}
+			b.roundStats.Decisions = append(b.roundStats.Decisions, d)
+			if !d.Engage {
+				b.log("🤔 Judge: skip %s by @%s (confidence %.2f): %s", truncate(p.Title, 40), p.AgentName, d.Confidence, d.Reason)
+				b.remember("declined", p.ID, "") // not "post": discover may still vote for it
+				continue
 			}
+			b.log("🤔 Judge: engage (confidence %.2f): %s", d.Confidence, d.Reason)
+			b.because("relevance score %s ≥ %s; judge %.2f: %s", r, formatScore(threshold), d.Confidence, d.Reason)
 		}
+		b.log("💬 Engaging with: %s by @%s, score %s", truncate(p.Title, 40), p.AgentName, r)
+		comment := b.generateComment(ctx, p)
+		if ctx.Err() != nil {
+			break // shutting down; the post was not commented on
+		}
+		if comment == "" {
+			b.logWarn("⚠️ No comment generated for post #%d, skipping it", p.ID)
+			b.remember("post", p.ID, "")
+			continue
+		}
+		id, err := b.Comment(ctx, p.ID, 0, comment)
+		if err != nil {
+			b.logError("❌ Failed to comment on post #%d: %v", p.ID, err)
+			continue // 不标记为已处理，下轮重试
+		}
+		b.log("✅ Commented on post #%d", p.ID)
+		b.record(history.Entry{Kind: "comment", PostID: p.ID, CommentID: id, Agent: p.AgentName, Received: p.Title, Sent: comment})
+		b.remember("post", p.ID, "")
+		b.remember("agent", 0, p.AgentName) // Track interaction
+		engaged++
+		b.roundStats.EngagementsCount++
+		b.roundStats.EngagedWith = append(b.roundStats.EngagedWith, "@"+p.AgentName)
+		if tweet := b.generateTweet(ctx, "Engagement", fmt.Sprintf("Connected with @%s", p.AgentName)); tweet != "" {
+			b.saveTweet("Engagement", tweet)
+		}
+	}
+	b.focus(0)
+}
+
+// score rates p with the relevance scorer. A failed embedding request is
+// logged and the keyword score used alone.
+func (b *Bot) score(ctx context.Context, p colosseum.Post) relevance {
+	r, err := b.relevance.score(ctx, p)
+	if err != nil && ctx.Err() == nil {
+		b.logWarn("⚠️ Embedding failed, using keywords only: %v", err)
 	}
+	return r
 }
 
-func (b *Bot) CheckMentions() {
+// StatusReport is what ShowStatus found; either part is nil if its request failed.
+type StatusReport struct {
+	Status  *colosseum.AgentStatus `json:"status"`
+	Project *colosseum.Project     `json:"project"`
+}
+
+func (b *Bot) ShowStatus(ctx context.Context) StatusReport {
+	var r StatusReport
+	b.log("=== 📊 Agent Status ===")
+	if s, err := b.GetStatus(ctx); err != nil {
+		b.logError("❌ Failed to get status: %v", err)
+	} else {
+		b.log("Status: %s | Hackathon: %v", s.Status, s.Hackathon.IsActive)
+		b.log("Posts: %d | Replies: %d | Project: %s", s.Engagement.ForumPostCount, s.Engagement.RepliesOnYourPosts, s.Engagement.ProjectStatus)
+		r.Status = s
+	}
+
+	b.log("=== 📦 My Project ===")
+	if p, err := b.GetProject(ctx); err != nil {
+		b.logError("❌ Failed to get project: %v", err)
+	} else {
+		b.log("%s | Votes: Agent %d / Human %d", p.Name, p.AgentUpvotes, p.HumanUpvotes)
+		metricProjectUpvotes.Set(float64(p.AgentUpvotes), b.cfg.Agent.Name, "agent")
+		metricProjectUpvotes.Set(float64(p.HumanUpvotes), b.cfg.Agent.Name, "human")
+		r.Project = p
+	}
+	return r
+}
+
+func (b *Bot) CheckMentions(ctx context.Context) {
 	b.log("=== 🔔 Checking mentions ===")
-	data, _ := b.request("GET", "/forum/search?q=moltpost&limit=20", nil)
-	var r struct{ Results []struct{ AgentName string } }
-	json.Unmarshal(data, &r)
-	if len(r.Results) > 0 {
-		b.log("Found %d mentions", len(r.Results))
+	results, err := b.api.SearchForum(ctx, "moltpost", b.fetchLimit(20))
+	if err != nil {
+		b.logError("❌ Failed to search mentions: %v", err)
+		return
+	}
+	b.roundStats.Mentions = len(results)
+	if len(results) > 0 {
+		b.log("Found %d mentions", len(results))
 	} else {
 		b.log("No mentions found")
 	}
 }
 
-func (b *Bot) CheckLeaderboard() {
+func (b *Bot) CheckLeaderboard(ctx context.Context) {
 	b.log("=== 🏆 Checking leaderboard ===")
-	projects, _ := b.GetLeaderboard()
+	projects, err := b.GetLeaderboard(ctx, b.fetchLimit(10))
+	if err != nil {
+		b.logError("❌ Failed to get leaderboard: %v", err)
+		return
+	}
+	rank := 0
 	for i, p := range projects {
 		if strings.Contains(strings.ToLower(p.Name), "moltpost") {
 			b.log("🎉 Moltpost is #%d!", i+1)
-			b.roundStats.LeaderboardRank = i + 1
+			rank = i + 1
 		}
 	}
+	b.roundStats.LeaderboardRank = rank
+	metricLeaderboardRank.Set(float64(rank), b.cfg.Agent.Name)
 }
 
-func (b *Bot) PostProgress() {
-	if time.Since(b.lastProgressPost) < 24*time.Hour {
+func (b *Bot) PostProgress(ctx context.Context) {
+	if !b.due("post-progress", b.lastProgressPost) {
 		return
 	}
 	b.log("=== 📝 Posting progress update ===")
-	body := b.generateProgress()
+	body := b.generateProgress(ctx)
 	if body == "" {
+		b.logWarn("⚠️ No progress update generated")
 		return
 	}
-	startDate, _ := time.Parse("2006-01-02", cfg.Progress.StartDate)
+	startDate, _ := time.Parse("2006-01-02", b.cfg.Progress.StartDate)
 	day := int(time.Since(startDate).Hours()/24) + 1
 	title := fmt.Sprintf("Moltpost Progress Update - Day %d", day)
-	if b.CreatePost(title, body, cfg.Progress.Tags) == nil {
+	b.because("post-progress schedule due (day %d)", day)
+	if id, err := b.CreatePost(ctx, title, body, b.cfg.Progress.Tags); err != nil {
+		b.logError("❌ Failed to post progress update: %v", err)
+	} else {
 		b.log("✅ Posted progress update")
-		b.lastProgressPost = time.Now()
+		b.record(history.Entry{Kind: "progress", PostID: id, Sent: title + "\n\n" + body})
+		b.remember("progress", 0, "")
+		b.watchPost(id)
 		b.roundStats.ProgressPosted = true
-		if tweet := b.generateTweet("Progress", fmt.Sprintf("Day %d progress", day)); tweet != "" {
+		if tweet := b.generateTweet(ctx, "Progress", fmt.Sprintf("Day %d progress", day)); tweet != "" {
 			b.saveTweet("Progress", tweet)
 		}
 	}
 }
 
-func (b *Bot) PostNew() {
+func (b *Bot) PostNew(ctx context.Context) {
 	b.log("=== 📮 Checking new post ===")
-	if !cfg.Posting.Enabled {
-		b.log("⚠️ Posting disabled in config")
+	if !b.cfg.Posting.Enabled {
+		b.logWarn("⚠️ Posting disabled in config")
 		return
 	}
-	interval := time.Duration(cfg.Posting.Interval) * time.Minute
-	if interval == 0 {
-		interval = 30 * time.Minute
-	}
-	if time.Since(b.lastNewPost) < interval {
-		b.log("⏳ New post cooldown: %v remaining", interval-time.Since(b.lastNewPost))
+	if !b.due("post-new", b.lastNewPost) {
 		return
 	}
 
 	b.log("=== 📮 Creating new post ===")
-	title, body, tags := b.generateNewPost()
+	title, body, tags := b.generateNewPost(ctx)
 	if title == "" || body == "" {
-		b.log("⚠️ Failed to generate new post content")
+		b.logWarn("⚠️ Failed to generate new post content")
 		return
 	}
 
 	b.log("Title: %s", title)
 	b.log("Tags: %v", tags)
+	b.because("post-new schedule due")
 
-	if b.CreatePost(title, body, tags) == nil {
+	if id, err := b.CreatePost(ctx, title, body, tags); err != nil {
+		b.logError("❌ Failed to create post: %v", err)
+	} else {
 		b.log("✅ Posted new content: %s", title)
-		b.lastNewPost = time.Now()
+		b.record(history.Entry{Kind: "post", PostID: id, Sent: title + "\n\n" + body})
+		b.remember("new_post", b.topicIndex, "")
+		b.watchPost(id)
 		b.roundStats.NewPostPosted = true
-		if tweet := b.generateTweet("NewPost", title); tweet != "" {
+		if tweet := b.generateTweet(ctx, "NewPost", title); tweet != "" {
 			b.saveTweet("NewPost", tweet)
 		}
 	}
@@ -919,55 +1193,119 @@ func (b *Bot) PostNew() {
 
 // ==================== Main ====================
 
-func (b *Bot) RunHeartbeat() {
-	b.resetRoundStats()
-	b.log("")
-	b.log("════════════════════════════════════════════════════════════")
-	b.log("🤖 Nanopost Heartbeat (with 智谱 AI)")
-	b.log("════════════════════════════════════════════════════════════")
+// RunHeartbeat runs every action once, regardless of the schedule (the
+// post actions still wait for their own interval).
+func (b *Bot) RunHeartbeat(ctx context.Context) { b.runRound(ctx, heartbeatActions) }
 
-	b.log("=== 📊 Agent Status ===")
-	if s, err := b.GetStatus(); err == nil {
-		b.log("Status: %s | Hackathon: %v", s.Status, s.Hackathon.IsActive)
-		b.log("Posts: %d | Replies: %d | Project: %s", s.Engagement.ForumPostCount, s.Engagement.RepliesOnYourPosts, s.Engagement.ProjectStatus)
-	}
+// runRound runs the named actions in heartbeat order and saves the round.
+func (b *Bot) runRound(ctx context.Context, names []string) {
+	b.resetRoundStats()
+	metricHeartbeats.Inc(b.cfg.Agent.Name)
+	b.retryBudget.Reset(time.Duration(b.cfg.Retry.MaxHeartbeatSeconds) * time.Second)
+	b.banner("")
+	b.banner("════════════════════════════════════════════════════════════")
+	b.log("🤖 Nanopost Heartbeat (with %s/%s)", b.llm.Name(), b.llm.Model())
+	if len(names) < len(heartbeatActions) {
+		b.log("📅 Due: %s", strings.Join(names, ", "))
+	}
+	b.banner("════════════════════════════════════════════════════════════")
+	b.publish(func(s *BotStatus) {
+		s.Running, s.Due, s.RoundStarted, s.Round = true, names, time.Now(), RoundStats{}
+	})
 
-	b.log("=== 📦 My Project ===")
-	if p, err := b.GetProject(); err == nil {
-		b.log("%s | Votes: Agent %d / Human %d", p.Name, p.AgentUpvotes, p.HumanUpvotes)
+	for _, name := range names {
+		if ctx.Err() != nil {
+			b.log("🛑 Heartbeat interrupted, saving what was done so far")
+			break
+		}
+		b.action, b.post = name, 0
+		if writeActions[name] && b.paused.Load() {
+			b.log("⏸️ Skipping %s: write actions are paused", name)
+			continue
+		}
+		findActionCommand(name).run(ctx, b)
+		b.publish(func(s *BotStatus) { s.Round = b.roundStats })
+	}
+	b.action, b.post = "", 0
+	// Saving only touches local files, so it runs even after cancellation.
+	if b.dryRun {
+		if err := b.saveDryRunReport(); err != nil {
+			b.logError("❌ Failed to save dry-run report: %v", err)
+		}
+	} else {
+		b.saveRoundSummary()
+		if err := b.saveState(); err != nil { // 保存状态，避免重复处理
+			b.logError("❌ Failed to save state: %v", err)
+		}
+		b.flushRateLimits()
 	}
 
-	b.CheckComments()
-	b.DiscoverAndVote()
-	b.VoteProjects() // 给其他项目投票
-	if time.Now().Minute() < 30 {
-		b.EngageWithPosts()
-	}
-	b.CheckMentions()
-	b.CheckLeaderboard()
-	b.PostNew()      // 每30分钟发新帖
-	b.PostProgress() // 每24小时发进度
-	b.saveRoundSummary()
-	b.saveState() // 保存状态，避免重复处理
+	b.publish(func(s *BotStatus) {
+		s.Running, s.Due, s.LastHeartbeat, s.Round = false, nil, time.Now(), b.roundStats
+	})
+	b.publishState()
 
-	b.log("")
+	b.banner("")
 	b.log("✅ Heartbeat Complete")
-	b.log("════════════════════════════════════════════════════════════")
+	b.banner("════════════════════════════════════════════════════════════")
 }
 
-func (b *Bot) StartLoop(interval int) {
-	b.log("🚀 Starting heartbeat loop (interval: %d minutes)", interval)
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
-	defer ticker.Stop()
+// StartLoop runs each action when the scheduler says it is due, until ctx
+// is cancelled. Actions due at the same time share one round. A round in
+// progress stops at the next request or wait and still saves its state.
+// interval (minutes) is the fallback for actions without their own timing.
+func (b *Bot) StartLoop(ctx context.Context, interval int) {
+	sched, err := newScheduler(b.cfg, interval, b.lastProgressPost, b.lastNewPost)
+	if err != nil {
+		b.logError("❌ %v", err)
+		return
+	}
+	b.log("🚀 Starting scheduler (time zone %s)", sched.Location)
+	b.publishState()
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
 
-	b.RunHeartbeat()
 	for {
+		if due := sched.Due(time.Now()); len(due) > 0 {
+			start := time.Now()
+			b.runRound(ctx, due)
+			for _, name := range due {
+				sched.Done(name, start)
+			}
+			b.keepPostIntervals(sched, due, start, interval)
+		}
+		if ctx.Err() != nil {
+			b.log("🛑 Shutting down...")
+			return
+		}
+		var wake <-chan time.Time // nil: wait for a signal only
+		at, names, ok := sched.Next(time.Now())
+		b.publish(func(s *BotStatus) { s.NextRun, s.NextActions = at, names })
+		if ok {
+			b.log("⏰ Next: %s at %s", strings.Join(names, ", "), at.Format("2006-01-02 15:04:05 MST"))
+			wake = time.After(time.Until(at))
+		} else {
+			b.logWarn("⚠️ No action is scheduled to run again")
+		}
 		select {
-		case <-ticker.C:
-			b.RunHeartbeat()
-		case <-sigChan:
+		case <-wake:
+			if b.watch.changed() {
+				b.reloadConfig("config files changed")
+				sched = b.rebuildScheduler(sched, interval)
+			}
+		case <-hupChan:
+			b.reloadConfig("SIGHUP")
+			sched = b.rebuildScheduler(sched, interval)
+		case <-b.trigger:
+			b.log("▶️ Heartbeat requested via control API")
+			start := time.Now()
+			b.runRound(ctx, heartbeatActions)
+			for _, name := range heartbeatActions {
+				sched.Done(name, start)
+			}
+			b.keepPostIntervals(sched, heartbeatActions, start, interval)
+		case <-ctx.Done():
 			b.log("🛑 Shutting down...")
 			return
 		}
@@ -975,33 +1313,154 @@ func (b *Bot) StartLoop(interval int) {
 }
 
 func main() {
-	if ColosseumAPIKey == "" {
-		log.Fatal("❌ COLOSSEUM_API_KEY required")
+	args, flags, err := parseCommandFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	args, overrides, err := parseConfigFlags(args)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	interval := 0 // 0 = each agent's bot.default_interval_minutes
+	action := findActionCommand(command)
+
+	dir := findConfigDir()
+	switch command {
+	case "help", "-h", "--help":
+		printUsage()
+		return
+	case "validate":
+		os.Exit(runValidate(dir, overrides))
+	case "config":
+		if len(args) < 2 || args[1] != "print" {
+			log.Fatal("usage: nanopost config print")
+		}
+		c, sources, err := readConfig(filepath.Join(dir, "config.yaml"), overrides)
+		var issues ConfigIssues
+		if err != nil && !errors.As(err, &issues) {
+			log.Fatalf("❌ %v", err)
+		}
+		printConfig(c, sources) // still useful when validation fails
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\n❌ Invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "", "once", "history", "schedule":
+	case "dry-run":
+		flags.DryRun = true
+	default:
+		if action == nil {
+			if n, err := strconv.Atoi(command); err == nil && n > 0 {
+				interval = n
+			} else {
+				printUsage()
+				log.Fatalf("❌ unknown command %q", command)
+			}
+		}
 	}
-	if ZhipuAPIKey == "" {
-		log.Fatal("❌ ZHIPU_API_KEY required")
+	setups, _, err := loadAgents(dir, overrides)
+	if err != nil {
+		log.Fatalf("❌ Invalid configuration (run `nanopost validate`):\n%v", err)
+	}
+	if command == "schedule" {
+		if len(args) > 1 {
+			if interval, err = strconv.Atoi(args[1]); err != nil || interval < 1 {
+				log.Fatalf("❌ usage: nanopost schedule [minutes] [--limit N]")
+			}
+		}
+		if err := runSchedule(setups, interval, flags.Limit); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		return
+	}
+	if command == "history" {
+		if err := runHistory(setups, args[1:], flags); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		return
 	}
 
-	fmt.Println(`
+	quiet := flags.JSON && (action != nil || command == "once" || command == "dry-run")
+	if !quiet {
+		fmt.Println(`
 ╔═══════════════════════════════════════════╗
 ║     Nanopost - Lightweight Hackathon Bot  ║
 ║     "Where I Meets Thou"                  ║
 ╚═══════════════════════════════════════════╝`)
+	}
 
-	bot := NewBot()
-	defer bot.logFile.Close()
-	defer bot.tweetFile.Close()
-	defer bot.summaryFile.Close()
+	if err := runAgents(setups, dir, overrides, flags, command, action, interval, quiet); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
 
-	interval := cfg.Bot.DefaultInterval
-	if len(os.Args) > 1 {
-		if os.Args[1] == "once" {
-			bot.RunHeartbeat()
-			return
+// runAgents builds a bot per agent and runs the command. It returns errors
+// instead of exiting, so the deferred Close calls still flush every bot's
+// files.
+func runAgents(setups []AgentSetup, dir string, overrides map[string]string, flags commandFlags, command string, action *actionCommand, interval int, quiet bool) error {
+	// SIGINT/SIGTERM cancel ctx: requests and waits in flight return early,
+	// state is saved and the deferred Close calls flush the files. A second
+	// signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	var bots []*Bot
+	for _, setup := range setups {
+		bot, err := NewBot(setup, dir, overrides, len(setups) > 1)
+		if err != nil {
+			return err
+		}
+		defer bot.Close()
+		bot.dryRun, bot.limit, bot.quiet = flags.DryRun, flags.Limit, quiet
+		if bot.dryRun {
+			bot.limiter.Simulate()
+		}
+		bots = append(bots, bot)
+	}
+
+	switch {
+	case action != nil:
+		index := map[*Bot]int{}
+		for i, b := range bots {
+			index[b] = i
+		}
+		results := make([]CommandResult, len(bots))
+		runBots(bots, func(b *Bot) { results[index[b]] = b.RunAction(ctx, action) })
+		if flags.JSON {
+			printResults(results)
+		}
+		return nil
+	case command == "once" || command == "dry-run":
+		runBots(bots, func(b *Bot) { b.RunHeartbeat(ctx) })
+		if flags.JSON {
+			results := make([]CommandResult, len(bots))
+			for i, b := range bots {
+				results[i] = CommandResult{Command: command, Agent: b.cfg.Agent.Name, DryRun: b.dryRun, Stats: b.roundStats, Planned: b.planned}
+			}
+			printResults(results)
 		}
-		fmt.Sscanf(os.Args[1], "%d", &interval)
+		return nil
 	}
 
-	fmt.Printf("🚀 Interval: %d min | AI: %s\n", interval, cfg.API.ZhipuModel)
-	bot.StartLoop(interval)
+	for _, b := range bots {
+		fmt.Printf("🚀 @%s | AI: %s/%s\n", b.cfg.Agent.Name, b.llm.Name(), b.llm.Model())
+	}
+	// The control API is process-wide, so it follows the first agent's config.
+	if cc := bots[0].cfg.Control; cc.Enabled {
+		if err := newControlServer(bots, os.Getenv(cc.TokenEnv)).serve(ctx, cc.Listen); err != nil {
+			return err
+		}
+		fmt.Printf("🎛️ Control API on http://%s\n", cc.Listen)
+	}
+	runBots(bots, func(b *Bot) { b.StartLoop(ctx, interval) })
+	return nil
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"nanopost/internal/colosseum"
+	"nanopost/internal/metrics"
+)
+
+// ==================== Metrics ====================
+//
+// Process-wide Prometheus metrics, served at /metrics by the control API
+// (so only in loop mode with control.enabled, behind its token). Every
+// series carries the agent name, so several agents share one scrape.
+
+var (
+	registry = metrics.NewRegistry()
+
+	metricInteractions = registry.Counter("nanopost_interactions_total",
+		"Successful writes: reply, vote, project_vote, comment (engagement), post, progress, tweet.", "agent", "kind")
+	metricHeartbeats = registry.Counter("nanopost_heartbeats_total",
+		"Heartbeat rounds run.", "agent")
+	metricAPIDuration = registry.Histogram("nanopost_api_request_duration_seconds",
+		"Colosseum API request latency per attempt.", nil, "agent", "method", "endpoint")
+	metricAPIResponses = registry.Counter("nanopost_api_responses_total",
+		"Colosseum API responses by status code; code 0 = no response.", "agent", "method", "endpoint", "code")
+	metricLLMDuration = registry.Histogram("nanopost_llm_request_duration_seconds",
+		"LLM call latency per attempt.", nil, "agent", "provider")
+	metricLLMFailures = registry.Counter("nanopost_llm_failures_total",
+		"Failed LLM call attempts.", "agent", "provider")
+	metricFallbackReplies = registry.Counter("nanopost_fallback_replies_total",
+		"Replies that used fallback_reply because the LLM failed.", "agent")
+	metricLeaderboardRank = registry.Gauge("nanopost_leaderboard_rank",
+		"Our rank in the last leaderboard check; 0 = not in the fetched top.", "agent")
+	metricProjectUpvotes = registry.Gauge("nanopost_project_upvotes",
+		"Upvotes on our project at the last status check.", "agent", "voter")
+)
+
+// observeAPI returns the API client's observer for an agent.
+func observeAPI(agent string) colosseum.Observer {
+	return func(method, route string, status int, elapsed time.Duration) {
+		metricAPIDuration.Observe(elapsed.Seconds(), agent, method, route)
+		metricAPIResponses.Inc(agent, method, route, strconv.Itoa(status))
+	}
+}
+
+// meteredProvider records the latency and failures of each LLM call.
+type meteredProvider struct {
+	LLMProvider
+	agent string
+}
+
+func (p *meteredProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	start := time.Now()
+	out, err := p.LLMProvider.Chat(ctx, messages)
+	metricLLMDuration.Observe(time.Since(start).Seconds(), p.agent, p.Name())
+	if err != nil {
+		metricLLMFailures.Inc(p.agent, p.Name())
+	}
+	return out, err
+}
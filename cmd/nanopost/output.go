@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ==================== Output Files ====================
+
+// datedFile appends to the tweet or summary file of the current output day
+// and moves on to the next file when the day (or the config) changes, so a
+// loop running for a week writes one file per day.
+type datedFile struct {
+	path func(now time.Time) string // set by configure
+	f    *os.File
+	name string
+}
+
+// roll makes sure the file for now is open. It reports whether a new file
+// was opened.
+func (d *datedFile) roll(now time.Time) (bool, error) {
+	name := d.path(now)
+	if d.f != nil && name == d.name {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	d.Close()
+	d.f, d.name = f, name
+	return true, nil
+}
+
+// empty reports whether the file for now has nothing in it yet, so a header
+// can go first.
+func (d *datedFile) empty(now time.Time) (bool, error) {
+	if _, err := d.roll(now); err != nil {
+		return false, err
+	}
+	info, err := d.f.Stat()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == 0, nil
+}
+
+func (d *datedFile) WriteString(s string) (int, error) {
+	if _, err := d.roll(time.Now()); err != nil {
+		return 0, err
+	}
+	return d.f.WriteString(s)
+}
+
+func (d *datedFile) Close() error {
+	if d.f == nil {
+		return nil
+	}
+	err := d.f.Sync()
+	if cerr := d.f.Close(); err == nil {
+		err = cerr
+	}
+	d.f = nil
+	return err
+}
+
+// outputPath puts relative output files into output.dir.
+func (c Config) outputPath(name string) string {
+	if c.Output.Dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.Output.Dir, name)
+}
+
+// parseDayStart reads output.day_starts_at ("HH:MM") as an offset from midnight.
+func parseDayStart(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// outputDay is the date an output file covers: the day starts at
+// output.day_starts_at in schedule.timezone.
+func (c Config) outputDay() func(time.Time) string {
+	loc, err := c.scheduleLocation()
+	if err != nil {
+		loc = time.Local
+	}
+	start, _ := parseDayStart(c.Output.DayStartsAt)
+	return func(t time.Time) string {
+		return t.In(loc).Add(-start).Format("2006-01-02")
+	}
+}
+
+// datedPath returns the file name for a *_file_pattern at a given time.
+func (c Config) datedPath(pattern string) func(time.Time) string {
+	day := c.outputDay()
+	return func(t time.Time) string {
+		return c.outputPath(fmt.Sprintf(pattern, day(t)))
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDatedFileRollsOver(t *testing.T) {
+	dir := t.TempDir()
+	var c Config
+	c.Output.Dir = filepath.Join(dir, "out") // created on first use
+	c.Schedule.Timezone = "UTC"
+	c.Output.DayStartsAt = "04:00"
+	d := &datedFile{path: c.datedPath("tweets_%s.md")}
+	defer d.Close()
+
+	steps := []struct {
+		at      string
+		wantNew bool
+		file    string
+	}{
+		{"2026-02-01 10:00", true, "tweets_2026-02-01.md"},
+		{"2026-02-01 23:59", false, "tweets_2026-02-01.md"},
+		{"2026-02-02 03:59", false, "tweets_2026-02-01.md"}, // the day starts at 04:00
+		{"2026-02-02 04:00", true, "tweets_2026-02-02.md"},
+	}
+	for _, s := range steps {
+		now, _ := time.Parse("2006-01-02 15:04", s.at)
+		opened, err := d.roll(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opened != s.wantNew || d.name != filepath.Join(c.Output.Dir, s.file) {
+			t.Errorf("%s: opened = %v, file = %s; want %v, %s", s.at, opened, d.name, s.wantNew, s.file)
+		}
+		d.f.WriteString(s.at + "\n")
+	}
+	d.Close()
+	data, _ := os.ReadFile(filepath.Join(c.Output.Dir, "tweets_2026-02-01.md"))
+	if string(data) != "2026-02-01 10:00\n2026-02-01 23:59\n2026-02-02 03:59\n" {
+		t.Errorf("first day's file = %q", data)
+	}
+}
+
+func TestDatedFileFollowsConfig(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	d := &datedFile{path: func(time.Time) string { return filepath.Join(dir, "a.md") }}
+	defer d.Close()
+	if _, err := d.WriteString("one\n"); err != nil {
+		t.Fatal(err)
+	}
+	// configure swaps the pattern; the next write goes to the new file.
+	d.path = func(time.Time) string { return filepath.Join(dir, "b.md") }
+	if opened, _ := d.roll(now); !opened {
+		t.Error("a new pattern did not open a new file")
+	}
+	d.WriteString("two\n")
+	if a, _ := os.ReadFile(filepath.Join(dir, "a.md")); string(a) != "one\n" {
+		t.Errorf("a.md = %q", a)
+	}
+}
+
+func TestDatedFileEmpty(t *testing.T) {
+	dir := t.TempDir()
+	d := &datedFile{path: func(now time.Time) string { return filepath.Join(dir, now.Format("2006-01-02")+".html") }}
+	defer d.Close()
+	day1 := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+	if empty, err := d.empty(day1); err != nil || !empty {
+		t.Fatalf("new file: empty = %v, %v", empty, err)
+	}
+	d.f.WriteString("<!DOCTYPE html>\n")
+	if empty, _ := d.empty(day1); empty {
+		t.Error("file with a head reported empty")
+	}
+	// A restart reopens the same day's file: it already has its head.
+	d.Close()
+	if empty, _ := d.empty(day1); empty {
+		t.Error("reopened file reported empty")
+	}
+	if empty, _ := d.empty(day1.AddDate(0, 0, 1)); !empty {
+		t.Error("next day's file should start empty")
+	}
+}
+
+func TestParseDayStart(t *testing.T) {
+	for in, want := range map[string]time.Duration{"": 0, "00:00": 0, "04:30": 4*time.Hour + 30*time.Minute, "23:59": 23*time.Hour + 59*time.Minute} {
+		if got, err := parseDayStart(in); err != nil || got != want {
+			t.Errorf("parseDayStart(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"4am", "24:00", "4:5:6"} {
+		if _, err := parseDayStart(in); err == nil {
+			t.Errorf("parseDayStart(%q) accepted", in)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	var c Config
+	if got := c.outputPath("log.txt"); got != "log.txt" {
+		t.Errorf("no dir: %s", got)
+	}
+	c.Output.Dir = "out"
+	if got := c.outputPath("log.txt"); got != filepath.Join("out", "log.txt") {
+		t.Errorf("relative: %s", got)
+	}
+	if got := c.outputPath("/var/log/x.txt"); got != "/var/log/x.txt" {
+		t.Errorf("absolute paths stay as they are: %s", got)
+	}
+}
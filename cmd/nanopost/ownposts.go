@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"nanopost/internal/colosseum"
+)
+
+// ==================== Own Posts ====================
+//
+// Replies go to comments on agent.post_id and on the posts the bot created
+// itself (progress updates and new posts). Their IDs come from the create
+// response and are kept in the state next to the handled IDs; each round
+// the newest bot.watch_own_posts of them (all if 0) are checked, every
+// page of comments.
+
+const (
+	commentPageSize = 50 // the most the API returns per request
+	maxCommentPages = 10 // per post and round
+)
+
+// watchPost remembers a post we just created, so comments on it get
+// replies. Dry runs don't learn an ID.
+func (b *Bot) watchPost(id int) {
+	if id == 0 {
+		if !b.dryRun {
+			b.logWarn("⚠️ The API returned no post ID; comments on this post won't be answered")
+		}
+		return
+	}
+	b.remember("own_post", id, "")
+}
+
+// ownPosts returns the IDs of the posts we created, newest first.
+func (b *Bot) ownPosts() []int {
+	var ids []int
+	for id := range b.seen.ownPosts {
+		ids = append(ids, id)
+	}
+	if b.db != nil {
+		stored, err := b.db.IDs("own_post")
+		if err != nil {
+			b.logError("❌ Failed to read our posts: %v", err)
+		}
+		ids = append(ids, stored...)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	return ids
+}
+
+// watchedPosts returns the posts whose comments get replies: agent.post_id
+// first, then our posts, newest first, up to bot.watch_own_posts if set.
+func (b *Bot) watchedPosts() []int {
+	var ids []int
+	listed := map[int]bool{}
+	if b.cfg.Agent.PostID > 0 {
+		ids = append(ids, b.cfg.Agent.PostID)
+		listed[b.cfg.Agent.PostID] = true
+	}
+	own := 0
+	for _, id := range b.ownPosts() {
+		if n := b.cfg.Bot.WatchOwnPosts; n > 0 && own == n {
+			break
+		}
+		if !listed[id] {
+			ids = append(ids, id)
+			listed[id] = true
+			own++
+		}
+	}
+	return ids
+}
+
+// GetComments returns every comment on a post, newest first, reading up to
+// maxCommentPages pages. A page with nothing new ends the listing too, in
+// case the API ignores the offset.
+func (b *Bot) GetComments(ctx context.Context, postID int) ([]colosseum.Comment, error) {
+	var all []colosseum.Comment
+	seen := map[int]bool{}
+	for page := 0; page < maxCommentPages; page++ {
+		batch, err := b.api.Comments(ctx, postID, commentPageSize, page*commentPageSize)
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, c := range batch {
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				all = append(all, c)
+				added++
+			}
+		}
+		if len(batch) < commentPageSize || added == 0 {
+			return all, nil
+		}
+	}
+	b.logWarn("⚠️ Post #%d has more than %d comments; only the newest were read", postID, len(all))
+	return all, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"nanopost/internal/colosseum"
+)
+
+// commentsAPI serves total comments on any post, newest (highest ID) first,
+// honouring limit and offset unless ignoreOffset is set. It returns the bot
+// and the offsets requested.
+func commentsAPI(t *testing.T, total int, ignoreOffset bool) (*Bot, *[]int) {
+	var offsets []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		offsets = append(offsets, offset)
+		if ignoreOffset {
+			offset = 0
+		}
+		var page []colosseum.Comment
+		for id := total - offset; id > 0 && len(page) < limit; id-- {
+			page = append(page, colosseum.Comment{ID: id})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"comments": page})
+	}))
+	t.Cleanup(srv.Close)
+	return &Bot{api: colosseum.NewClient(srv.URL, "k", srv.Client()), quiet: true}, &offsets
+}
+
+func TestGetCommentsPaging(t *testing.T) {
+	tests := []struct {
+		name         string
+		total        int
+		ignoreOffset bool
+		wantOffsets  []int
+		wantCount    int
+	}{
+		{"one short page", 7, false, []int{0}, 7},
+		{"no comments", 0, false, []int{0}, 0},
+		{"short last page", 120, false, []int{0, 50, 100}, 120},
+		{"exact multiple needs an empty page", 100, false, []int{0, 50, 100}, 100},
+		{"offset ignored: a page with nothing new stops", 80, true, []int{0, 50}, 50},
+		{"page cap", 2000, false, []int{0, 50, 100, 150, 200, 250, 300, 350, 400, 450}, maxCommentPages * commentPageSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, offsets := commentsAPI(t, tt.total, tt.ignoreOffset)
+			got, err := b.GetComments(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*offsets, tt.wantOffsets) {
+				t.Errorf("offsets = %v, want %v", *offsets, tt.wantOffsets)
+			}
+			if len(got) != tt.wantCount {
+				t.Errorf("got %d comments, want %d", len(got), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestGetCommentsDropsShiftedDuplicates(t *testing.T) {
+	// Two comments arrive between the first and second request, so the
+	// second page starts with the last two of the first.
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		newest := 60
+		if calls > 1 {
+			newest = 62
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		var page []colosseum.Comment
+		for id := newest - offset; id > 0 && len(page) < commentPageSize; id-- {
+			page = append(page, colosseum.Comment{ID: id})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"comments": page})
+	}))
+	defer srv.Close()
+	b := &Bot{api: colosseum.NewClient(srv.URL, "k", srv.Client()), quiet: true}
+	got, err := b.GetComments(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int]bool{}
+	for _, c := range got {
+		if seen[c.ID] {
+			t.Fatalf("comment %d listed twice", c.ID)
+		}
+		seen[c.ID] = true
+	}
+	if len(got) != 60 || got[0].ID != 60 || got[len(got)-1].ID != 1 {
+		t.Errorf("got %d comments from #%d to #%d, want 60 to 1", len(got), got[0].ID, got[len(got)-1].ID)
+	}
+}
+
+func TestGetCommentsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	b := &Bot{api: colosseum.NewClient(srv.URL, "k", srv.Client()), quiet: true}
+	if got, err := b.GetComments(context.Background(), 1); !colosseum.IsStatus(err, 404) || got != nil {
+		t.Errorf("GetComments = %v, %v; want the 404", got, err)
+	}
+}
+
+func TestWatchedPosts(t *testing.T) {
+	b := &Bot{seen: newSeenSet(), quiet: true}
+	b.cfg.Agent.PostID = 186
+	for _, id := range []int{300, 186, 250, 400} {
+		b.seen.add("own_post", id, "")
+	}
+	b.cfg.Bot.WatchOwnPosts = 2
+	if got := b.watchedPosts(); !reflect.DeepEqual(got, []int{186, 400, 300}) {
+		t.Errorf("watchedPosts = %v, want the pinned post and the newest two of ours", got)
+	}
+	b.cfg.Bot.WatchOwnPosts = 0
+	if got := b.watchedPosts(); !reflect.DeepEqual(got, []int{186, 400, 300, 250}) {
+		t.Errorf("watch_own_posts 0: watchedPosts = %v, want all of them", got)
+	}
+	if n := defaultConfig().Bot.WatchOwnPosts; n != 20 {
+		t.Errorf("default watch_own_posts = %d, want a bounded 20", n)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"nanopost/internal/colosseum"
+	"nanopost/internal/ratelimit"
+	"nanopost/internal/retry"
+)
+
+// ==================== Relevance ====================
+//
+// Discover and engage act on posts whose relevance score reaches a
+// threshold. The score is the sum of the weights of the keywords found as
+// whole words in the title and body, minus the penalties of negative
+// keywords, plus (optionally) the embedding similarity between the post and
+// a topic description times a weight.
+
+type RelevanceConfig struct {
+	Weights         map[string]float64 `yaml:"weights"`          // keyword -> weight; keywords not listed weigh 1, extra entries are added
+	Negative        map[string]float64 `yaml:"negative"`         // keyword -> penalty subtracted when it appears
+	VoteThreshold   float64            `yaml:"vote_threshold"`   // discover votes for posts scoring at least this
+	EngageThreshold float64            `yaml:"engage_threshold"` // engage comments on posts scoring at least this
+	Embedding       EmbeddingConfig    `yaml:"embedding"`
+}
+
+type EmbeddingConfig struct {
+	Enabled bool    `yaml:"enabled"`
+	Topic   string  `yaml:"topic"`  // what the agent cares about, compared with each post
+	Weight  float64 `yaml:"weight"` // cosine similarity (0-1) times this is added to the score
+	Model   string  `yaml:"model"`  // empty = provider default
+	URL     string  `yaml:"url"`    // empty = derived from api.llm.url
+}
+
+// relevance is a score and the terms that make it up, for the log.
+type relevance struct {
+	score   float64
+	reasons []string
+}
+
+func (r relevance) String() string {
+	if len(r.reasons) == 0 {
+		return formatScore(r.score) + " (no keywords)"
+	}
+	return formatScore(r.score) + " (" + strings.Join(r.reasons, ", ") + ")"
+}
+
+func formatScore(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+type term struct {
+	word   string
+	tokens []string
+	weight float64 // negative for negative keywords
+}
+
+// relevanceScorer scores posts for one agent. It is rebuilt on config
+// reload and only used from the bot's own goroutine.
+type relevanceScorer struct {
+	terms    []term
+	embed    *embedder // nil unless relevance.embedding.enabled
+	topic    string
+	weight   float64
+	topicVec []float64
+	cache    map[int][]float64 // post ID -> embedding
+}
+
+func newRelevanceScorer(c Config, e *embedder) *relevanceScorer {
+	s := &relevanceScorer{embed: e, topic: c.Relevance.Embedding.Topic, weight: c.Relevance.Embedding.Weight, cache: map[int][]float64{}}
+	listed := map[string]bool{}
+	for _, kw := range c.Keywords {
+		w, ok := c.Relevance.Weights[kw]
+		if !ok {
+			w = 1
+		}
+		listed[kw] = true
+		s.terms = append(s.terms, term{kw, words(kw), w})
+	}
+	for _, kw := range sortedKeys(c.Relevance.Weights) {
+		if !listed[kw] {
+			s.terms = append(s.terms, term{kw, words(kw), c.Relevance.Weights[kw]})
+		}
+	}
+	for _, kw := range sortedKeys(c.Relevance.Negative) {
+		s.terms = append(s.terms, term{kw, words(kw), -c.Relevance.Negative[kw]})
+	}
+	return s
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// words splits text into lowercase words; punctuation and hyphens separate
+// words, so "multi-agent" contains "agent" but "agents" does not.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// containsPhrase reports whether phrase occurs as consecutive words in text.
+func containsPhrase(text, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(text); i++ {
+		match := true
+		for j, w := range phrase {
+			if text[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// score rates p. Each keyword counts once however often it appears. If the
+// embedding request fails, the keyword score is returned with the error.
+func (s *relevanceScorer) score(ctx context.Context, p colosseum.Post) (relevance, error) {
+	var r relevance
+	text := words(p.Title + " " + p.Body)
+	for _, t := range s.terms {
+		if containsPhrase(text, t.tokens) {
+			r.score += t.weight
+			r.reasons = append(r.reasons, fmt.Sprintf("%s %+g", t.word, t.weight))
+		}
+	}
+	if s.embed == nil {
+		return r, nil
+	}
+	sim, err := s.similarity(ctx, p)
+	if err != nil {
+		return r, err
+	}
+	r.score += sim * s.weight
+	r.reasons = append(r.reasons, fmt.Sprintf("topic similarity %s ×%g", formatScore(sim), s.weight))
+	return r, nil
+}
+
+func (s *relevanceScorer) similarity(ctx context.Context, p colosseum.Post) (float64, error) {
+	if s.topicVec == nil {
+		vec, err := s.embed.Embed(ctx, s.topic)
+		if err != nil {
+			return 0, fmt.Errorf("embed topic: %w", err)
+		}
+		s.topicVec = vec
+	}
+	vec, ok := s.cache[p.ID]
+	if !ok {
+		var err error
+		if vec, err = s.embed.Embed(ctx, p.Title+"\n\n"+p.Body); err != nil {
+			return 0, fmt.Errorf("embed post #%d: %w", p.ID, err)
+		}
+		if len(s.cache) >= 1000 {
+			s.cache = map[int][]float64{}
+		}
+		s.cache[p.ID] = vec
+	}
+	return math.Max(cosine(s.topicVec, vec), 0), nil
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// ---------- Embeddings ----------
+
+// embeddingDefaults are the embedding models of the providers that have an
+// embeddings endpoint next to their chat endpoint.
+var embeddingDefaults = map[string]string{
+	"zhipu":  "embedding-3",
+	"openai": "text-embedding-3-small",
+	"ollama": "nomic-embed-text",
+}
+
+// embedder calls the LLM provider's embeddings endpoint. It shares the
+// "llm" rate-limit bucket and the LLM retry policy with chat requests.
+type embedder struct {
+	ollama             bool // Ollama's /api/embed shape instead of OpenAI's /embeddings
+	url, model, apiKey string
+	client             *http.Client
+	limiter            *ratelimit.Limiter
+	policy             retry.Policy
+}
+
+func newEmbedder(c Config, client *http.Client, limiter *ratelimit.Limiter, policy retry.Policy) (*embedder, error) {
+	lc, err := resolveLLMConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	ec := c.Relevance.Embedding
+	model, ok := embeddingDefaults[lc.Provider]
+	if !ok {
+		return nil, fmt.Errorf("relevance.embedding: llm provider %s has no embeddings endpoint", lc.Provider)
+	}
+	e := &embedder{ollama: lc.Provider == "ollama", url: ec.URL, model: ec.Model, client: client, limiter: limiter, policy: policy}
+	if e.model == "" {
+		e.model = model
+	}
+	if e.url == "" {
+		switch {
+		case e.ollama && strings.HasSuffix(lc.URL, "/api/chat"):
+			e.url = strings.TrimSuffix(lc.URL, "/api/chat") + "/api/embed"
+		case !e.ollama && strings.HasSuffix(lc.URL, "/chat/completions"):
+			e.url = strings.TrimSuffix(lc.URL, "/chat/completions") + "/embeddings"
+		default:
+			return nil, fmt.Errorf("relevance.embedding.url: can't derive it from api.llm.url %s, set it", lc.URL)
+		}
+	}
+	if lc.APIKeyEnv != "" {
+		e.apiKey = os.Getenv(lc.APIKeyEnv)
+	}
+	return e, nil
+}
+
+type embeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"` // OpenAI, Zhipu
+	Embeddings [][]float64 `json:"embeddings"` // Ollama
+}
+
+func (e *embedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	if err := e.limiter.Wait(ctx, "llm"); err != nil {
+		return nil, err
+	}
+	var vec []float64
+	err := e.policy.Do(ctx, func() error {
+		var err error
+		vec, err = e.embed(ctx, text)
+		return err
+	})
+	e.limiter.Done("llm", err == nil)
+	return vec, err
+}
+
+func (e *embedder) embed(ctx context.Context, text string) ([]float64, error) {
+	headers := map[string]string{}
+	if e.apiKey != "" {
+		headers["Authorization"] = "Bearer " + e.apiKey
+	}
+	body, err := postJSON(ctx, e.client, e.url, headers, embeddingRequest{Model: e.model, Input: text})
+	if err != nil {
+		return nil, err
+	}
+	var r embeddingResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("embeddings: decode response: %w", err)
+	}
+	switch {
+	case e.ollama && len(r.Embeddings) > 0:
+		return r.Embeddings[0], nil
+	case !e.ollama && len(r.Data) > 0:
+		return r.Data[0].Embedding, nil
+	}
+	return nil, fmt.Errorf("embeddings: no vector in response")
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"nanopost/internal/colosseum"
+	"nanopost/internal/ratelimit"
+	"nanopost/internal/retry"
+)
+
+func TestWords(t *testing.T) {
+	for in, want := range map[string][]string{
+		"Hello, World!":       {"hello", "world"},
+		"multi-agent systems": {"multi", "agent", "systems"},
+		"GPT-4o v2.0":         {"gpt", "4o", "v2", "0"},
+		"人机 对话":               {"人机", "对话"},
+	} {
+		if got := words(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("words(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := words("  "); len(got) != 0 {
+		t.Errorf("words of blanks = %q", got)
+	}
+}
+
+func TestContainsPhrase(t *testing.T) {
+	has := func(text, phrase string) bool { return containsPhrase(words(text), words(phrase)) }
+	if !has("an AI agent here", "agent") || !has("a multi-agent demo", "agent") {
+		t.Error("whole word not found")
+	}
+	if has("many agents here", "agent") || has("the agentic web", "agent") {
+		t.Error("matched inside a longer word")
+	}
+	if !has("Human-AI dialogue", "human ai") {
+		t.Error("phrase across punctuation not found")
+	}
+	if has("human and ai", "human ai") || has("ends with human", "human ai") {
+		t.Error("phrase matched with a gap or cut off")
+	}
+	if has("anything", "") {
+		t.Error("empty phrase matched")
+	}
+}
+
+func TestRelevanceScore(t *testing.T) {
+	var c Config
+	c.Keywords = []string{"agent", "identity", "human ai"}
+	c.Relevance.Weights = map[string]float64{"identity": 3, "solana": 0.5}
+	c.Relevance.Negative = map[string]float64{"airdrop": 2}
+	s := newRelevanceScorer(c, nil)
+	score := func(title, body string) relevance {
+		r, err := s.score(context.Background(), colosseum.Post{Title: title, Body: body})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if r := score("Weekly update", "Shipped a parser."); r.score != 0 || r.String() != "0 (no keywords)" {
+		t.Errorf("no keywords: %v", r)
+	}
+	if r := score("", "Agent identity on chain"); r.String() != "4 (agent +1, identity +3)" {
+		t.Errorf("listed weight: %v", r)
+	}
+	if r := score("Agent agent", "AGENT!"); r.score != 1 {
+		t.Errorf("a keyword counts once however often it appears: %v", r)
+	}
+	if r := score("Agents and agentic identities", ""); r.score != 0 {
+		t.Errorf("only whole words count: %v", r)
+	}
+	if r := score("Human-AI trust", ""); r.String() != "1 (human ai +1)" {
+		t.Errorf("phrase: %v", r)
+	}
+	if r := score("Built on Solana", ""); r.String() != "0.5 (solana +0.5)" {
+		t.Errorf("weights may add keywords: %v", r)
+	}
+	if r := score("Agent airdrop", ""); r.String() != "-1 (agent +1, airdrop -2)" {
+		t.Errorf("negative keyword: %v", r)
+	}
+}
+
+// fakeEmbeddings answers OpenAI-style embedding requests with [1, 0] for
+// the topic and [1, 1] for everything else, counting the calls.
+func fakeEmbeddings(t *testing.T, topic string) (*httptest.Server, *int) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req embeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		vec := "[1, 1]"
+		if req.Input == topic {
+			vec = "[1, 0]"
+		}
+		w.Write([]byte(`{"data":[{"embedding":` + vec + `}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRelevanceWithEmbeddings(t *testing.T) {
+	const topic = "agents meeting humans"
+	srv, calls := fakeEmbeddings(t, topic)
+	limiter, _ := ratelimit.New(nil, "")
+	e := &embedder{url: srv.URL, model: "m", client: srv.Client(), limiter: limiter}
+
+	var c Config
+	c.Keywords = []string{"agent"}
+	c.Relevance.Embedding = EmbeddingConfig{Enabled: true, Topic: topic, Weight: 2}
+	s := newRelevanceScorer(c, e)
+
+	post := colosseum.Post{ID: 9, Title: "An agent", Body: "says hi"}
+	for i := 0; i < 2; i++ {
+		r, err := s.score(context.Background(), post)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 1 for the keyword plus cos 45° (0.71) times 2.
+		if want := 1 + math.Sqrt2; math.Abs(r.score-want) > 1e-9 {
+			t.Errorf("score = %v, want %v", r.score, want)
+		}
+		if !strings.Contains(r.String(), "topic similarity 0.71 ×2") {
+			t.Errorf("reasons = %v", r)
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("embedding calls = %d, want 2 (the topic and the post, each once)", *calls)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := cosine([]float64{1, 2}, []float64{2, 4}); math.Abs(got-1) > 1e-9 {
+		t.Errorf("same direction = %v", got)
+	}
+	if got := cosine([]float64{1, 0}, []float64{-1, 0}); got != -1 {
+		t.Errorf("opposite = %v", got)
+	}
+	// Degenerate input scores 0 rather than NaN.
+	for _, pair := range [][2][]float64{{{1}, {1, 0}}, {{0, 0}, {1, 0}}, {nil, nil}} {
+		if got := cosine(pair[0], pair[1]); got != 0 {
+			t.Errorf("cosine(%v, %v) = %v, want 0", pair[0], pair[1], got)
+		}
+	}
+}
+
+func TestNewEmbedderURL(t *testing.T) {
+	var c Config
+	c.API.LLM = LLMConfig{Provider: "openai", URL: "https://api.example/v1/chat/completions"}
+	e, err := newEmbedder(c, nil, nil, retry.Policy{})
+	if err != nil || e.url != "https://api.example/v1/embeddings" || e.model != "text-embedding-3-small" {
+		t.Errorf("openai: %+v, %v", e, err)
+	}
+
+	c.API.LLM = LLMConfig{Provider: "ollama", URL: "http://localhost:11434/api/chat"}
+	if e, err = newEmbedder(c, nil, nil, retry.Policy{}); err != nil || e.url != "http://localhost:11434/api/embed" || !e.ollama {
+		t.Errorf("ollama: %+v, %v", e, err)
+	}
+
+	c.API.LLM = LLMConfig{Provider: "anthropic"}
+	if _, err = newEmbedder(c, nil, nil, retry.Policy{}); err == nil {
+		t.Error("anthropic has no embeddings endpoint, want an error")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ==================== Hot Reload ====================
+
+// configWatch remembers the modification times of config.yaml, the
+// agent's prompts file and the summary template file so the loop can tell
+// when they were edited.
+type configWatch struct {
+	dir   string
+	files []string // relative to dir; empty names are skipped
+	mods  []time.Time
+}
+
+func newConfigWatch(dir string, files ...string) configWatch {
+	w := configWatch{dir: dir, files: append([]string{"config.yaml"}, files...)}
+	w.mods = w.modTimes()
+	return w
+}
+
+func (w *configWatch) modTimes() []time.Time {
+	mods := make([]time.Time, len(w.files))
+	for i, name := range w.files {
+		if name == "" {
+			continue
+		}
+		if fi, err := os.Stat(filepath.Join(w.dir, name)); err == nil {
+			mods[i] = fi.ModTime()
+		}
+	}
+	return mods
+}
+
+// changed reports whether any file was modified since the last call.
+func (w *configWatch) changed() bool {
+	mods := w.modTimes()
+	same := len(mods) == len(w.mods)
+	for i := 0; same && i < len(mods); i++ {
+		same = mods[i].Equal(w.mods[i])
+	}
+	w.mods = mods
+	return !same
+}
+
+// reloadConfig re-reads the files between heartbeats and picks this bot's
+// agent by name. The new config and prompts are only swapped in when both
+// parse, the templates compile and the bot can be rebuilt from them;
+// otherwise the previous good version stays.
+func (b *Bot) reloadConfig(reason string) {
+	b.log("🔄 Reloading config (%s)", reason)
+	b.watch.changed() // remember the mtimes we are about to load
+	setups, _, err := loadAgents(b.watch.dir, b.overrides)
+	if err != nil {
+		b.logError("❌ Config reload failed, keeping previous config: %v", err)
+		return
+	}
+	var setup *AgentSetup
+	for i := range setups {
+		if setups[i].Config.Agent.Name == b.cfg.Agent.Name {
+			setup = &setups[i]
+		}
+	}
+	if setup == nil {
+		b.logError("❌ Agent @%s is no longer configured, keeping previous config", b.cfg.Agent.Name)
+		return
+	}
+	if err := b.configure(*setup); err != nil {
+		b.logError("❌ Config reload rejected, keeping previous config: %v", err)
+		return
+	}
+	b.watch = newConfigWatch(b.watch.dir, setup.Config.Agent.PromptsFile, setup.Config.Summary.TemplateFile)
+	b.log("✅ Config reloaded (%d keywords, AI: %s/%s)", len(b.cfg.Keywords), b.llm.Name(), b.llm.Model())
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"nanopost/internal/history"
+	"nanopost/internal/schedule"
+)
+
+// ==================== Schedule ====================
+
+// ScheduleConfig sets when each heartbeat action runs in loop mode.
+type ScheduleConfig struct {
+	Timezone     string         `yaml:"timezone"`    // IANA name, e.g. Asia/Shanghai; empty = local time
+	QuietHours   string         `yaml:"quiet_hours"` // HH:MM-HH:MM with no actions; each action may override
+	Status       ActionSchedule `yaml:"status"`
+	Reply        ActionSchedule `yaml:"reply"`
+	Discover     ActionSchedule `yaml:"discover"`
+	VoteProjects ActionSchedule `yaml:"vote_projects"`
+	Engage       ActionSchedule `yaml:"engage"`
+	Mentions     ActionSchedule `yaml:"mentions"`
+	Leaderboard  ActionSchedule `yaml:"leaderboard"`
+	PostNew      ActionSchedule `yaml:"post_new"`
+	PostProgress ActionSchedule `yaml:"post_progress"`
+}
+
+// ActionSchedule is one action's timing. With neither cron nor interval
+// set the action runs every bot.default_interval_minutes (or the interval
+// given on the command line).
+type ActionSchedule struct {
+	Cron            string `yaml:"cron"`             // five fields, e.g. "0-29/10 * * * *"; wins over interval
+	IntervalMinutes int    `yaml:"interval_minutes"` // run this long after the previous run
+	JitterSeconds   int    `yaml:"jitter_seconds"`   // random delay added to each run
+	QuietHours      string `yaml:"quiet_hours"`      // overrides schedule.quiet_hours; "off" disables
+}
+
+// heartbeatActions are the steps of a heartbeat, in the order they run.
+var heartbeatActions = []string{
+	"status", "reply", "discover", "vote-projects", "engage",
+	"mentions", "leaderboard", "post-new", "post-progress",
+}
+
+// action returns the schedule of a heartbeat action by command name.
+func (sc ScheduleConfig) action(name string) (ActionSchedule, string) {
+	switch name {
+	case "status":
+		return sc.Status, "status"
+	case "reply":
+		return sc.Reply, "reply"
+	case "discover":
+		return sc.Discover, "discover"
+	case "vote-projects":
+		return sc.VoteProjects, "vote_projects"
+	case "engage":
+		return sc.Engage, "engage"
+	case "mentions":
+		return sc.Mentions, "mentions"
+	case "leaderboard":
+		return sc.Leaderboard, "leaderboard"
+	case "post-new":
+		return sc.PostNew, "post_new"
+	case "post-progress":
+		return sc.PostProgress, "post_progress"
+	}
+	return ActionSchedule{}, ""
+}
+
+// scheduleAction builds the schedule of one action. interval is the
+// fallback in minutes (0 = bot.default_interval_minutes).
+func (c Config) scheduleAction(name string, interval int) (schedule.Action, error) {
+	as, key := c.Schedule.action(name)
+	if interval <= 0 {
+		interval = c.Bot.DefaultInterval
+	}
+	every := as.IntervalMinutes
+	if every == 0 && name == "post-new" {
+		every = c.Posting.Interval // legacy key
+	}
+	if every == 0 {
+		every = interval
+	}
+	spec, err := schedule.Parse(as.Cron, time.Duration(every)*time.Minute)
+	if err != nil {
+		return schedule.Action{}, fmt.Errorf("schedule.%s: %w", key, err)
+	}
+	quiet := c.Schedule.QuietHours
+	if as.QuietHours != "" {
+		quiet = as.QuietHours
+	}
+	window, err := schedule.ParseWindow(quiet)
+	if err != nil {
+		return schedule.Action{}, fmt.Errorf("schedule.%s: %w", key, err)
+	}
+	return schedule.Action{
+		Name:   name,
+		Spec:   spec,
+		Jitter: time.Duration(as.JitterSeconds) * time.Second,
+		Quiet:  window,
+	}, nil
+}
+
+func (c Config) scheduleLocation() (*time.Location, error) {
+	if c.Schedule.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Schedule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("schedule.timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// newScheduler builds the scheduler for c. The post actions start from
+// the persisted time of the last post, the rest run right away.
+func newScheduler(c Config, interval int, lastProgressPost, lastNewPost time.Time) (*schedule.Scheduler, error) {
+	loc, err := c.scheduleLocation()
+	if err != nil {
+		return nil, err
+	}
+	var actions []schedule.Action
+	for _, name := range heartbeatActions {
+		a, err := c.scheduleAction(name, interval)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	s := schedule.New(loc, actions)
+	s.SetLast("post-progress", lastProgressPost)
+	s.SetLast("post-new", lastNewPost)
+	return s, nil
+}
+
+// rebuildScheduler applies a reloaded config and keeps the last run times.
+func (b *Bot) rebuildScheduler(old *schedule.Scheduler, interval int) *schedule.Scheduler {
+	s, err := newScheduler(b.cfg, interval, b.lastProgressPost, b.lastNewPost)
+	if err != nil {
+		b.logError("❌ Schedule not changed: %v", err)
+		return old
+	}
+	for _, a := range old.Actions() {
+		if last := old.Last(a.Name); last.After(s.Last(a.Name)) {
+			s.SetLast(a.Name, last)
+		}
+	}
+	return s
+}
+
+// keepPostIntervals re-seeds the post actions after a round that ran them:
+// their interval only restarts when something was actually posted. One that
+// did not post (not due, failed or disabled) tries again a heartbeat
+// interval later.
+func (b *Bot) keepPostIntervals(s *schedule.Scheduler, ran []string, start time.Time, interval int) {
+	if interval <= 0 {
+		interval = b.cfg.Bot.DefaultInterval
+	}
+	for _, name := range ran {
+		var last time.Time
+		switch name {
+		case "post-progress":
+			last = b.lastProgressPost
+		case "post-new":
+			last = b.lastNewPost
+		default:
+			continue
+		}
+		if last.Before(start) {
+			s.Retry(name, last, start.Add(time.Duration(interval)*time.Minute))
+		} else {
+			s.SetLast(name, last)
+		}
+	}
+}
+
+// due reports whether a post action's interval or cron slot has come since
+// its last run, logging when it has not.
+func (b *Bot) due(name string, last time.Time) bool {
+	a, err := b.cfg.scheduleAction(name, 0)
+	if err != nil {
+		b.logError("❌ %v", err)
+		return false
+	}
+	if a.Due(last, time.Now()) {
+		return true
+	}
+	next := a.Spec.Next(last)
+	b.log("⏳ %s not due yet (%s): next at %s, %v remaining", name, a.Spec, next.Format("2006-01-02 15:04"), time.Until(next).Round(time.Second))
+	return false
+}
+
+// lastPosts reads the times of the last progress and new post from the
+// state, without building a full bot (no API keys needed).
+func lastPosts(c Config) (progress, newPost time.Time) {
+	b := &Bot{seen: newSeenSet(), stateFile: c.Agent.StateFile, cfg: c, quiet: true}
+	if c.State.Backend == "sqlite" {
+		if _, err := os.Stat(c.State.SQLiteFile); err != nil {
+			return
+		}
+		db, err := history.Open(c.State.SQLiteFile)
+		if err != nil {
+			return
+		}
+		defer db.Close()
+		b.db = db
+		b.loadMeta()
+	} else if b.loadState() == nil {
+		b.replayJournal()
+	}
+	return b.lastProgressPost, b.lastNewPost
+}
+
+// runSchedule implements `nanopost schedule`: the next planned runs of
+// every action, in the configured time zone.
+func runSchedule(setups []AgentSetup, interval, count int) error {
+	if count <= 0 {
+		count = 3
+	}
+	now := time.Now()
+	for _, setup := range setups {
+		c := setup.Config
+		progress, newPost := lastPosts(c)
+		s, err := newScheduler(c, interval, progress, newPost)
+		if err != nil {
+			return fmt.Errorf("agent %s: %w", c.Agent.Name, err)
+		}
+		fmt.Printf("@%s (time zone %s, now %s)\n", c.Agent.Name, s.Location, now.In(s.Location).Format("2006-01-02 15:04"))
+		runs := s.Upcoming(now, count)
+		for _, a := range s.Actions() {
+			var extra []string
+			if a.Jitter > 0 {
+				extra = append(extra, "jitter ≤"+a.Jitter.String())
+			}
+			if a.Quiet != nil {
+				extra = append(extra, "quiet "+a.Quiet.String())
+			}
+			desc := a.Spec.String()
+			if len(extra) > 0 {
+				desc += " (" + strings.Join(extra, ", ") + ")"
+			}
+			fmt.Printf("  %-14s %s\n", a.Name, desc)
+			var times []string
+			for _, r := range runs {
+				if r.Action == a.Name {
+					times = append(times, r.At.Format("01-02 15:04"))
+				}
+			}
+			if len(times) == 0 {
+				times = []string{"never"}
+			}
+			fmt.Printf("  %-14s → %s\n", "", strings.Join(times, ", "))
+		}
+	}
+	return nil
+}
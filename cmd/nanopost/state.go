@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"nanopost/internal/atomicfile"
+)
+
+// ==================== State ====================
+
+// stateVersion is the BotState layout this build writes. Older files are
+// upgraded on load by stateMigrations; newer ones are refused.
+const stateVersion = 2
+
+// State persistence - 持久化已处理的评论和帖子ID
+type BotState struct {
+	Version           int         `json:"version"`
+	ProcessedComments []int       `json:"processed_comments"`
+	ProcessedPosts    []int       `json:"processed_posts"`
+	VotedProjects     []int       `json:"voted_projects"`
+	InteractedAgents  []string    `json:"interacted_agents"`
+	OwnPosts          []int       `json:"own_posts"`      // posts we created, for replies to their comments
+	Replies           map[int]int `json:"replies"`        // their comment ID -> our reply's ID
+	DeclinedPosts     []int       `json:"declined_posts"` // posts the engagement judge said no to
+	LastProgressPost  time.Time   `json:"last_progress_post"`
+	LastNewPost       time.Time   `json:"last_new_post"`
+	TopicIndex        int         `json:"topic_index"`
+}
+
+// stateMigrations[v] upgrades a raw state document from version v to v+1.
+var stateMigrations = map[int]func(doc map[string]json.RawMessage) error{
+	// v1 files have no version field; the layout is otherwise the same.
+	1: func(doc map[string]json.RawMessage) error { return nil },
+}
+
+// decodeState parses a state file of any known version.
+func decodeState(data []byte) (BotState, error) {
+	var state BotState
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return state, err
+	}
+	if doc == nil {
+		return state, errors.New("not a JSON object")
+	}
+	version := 1
+	if raw, ok := doc["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return state, fmt.Errorf("bad version: %w", err)
+		}
+	}
+	if version > stateVersion {
+		return state, fmt.Errorf("version %d was written by a newer nanopost (this one reads up to %d)", version, stateVersion)
+	}
+	for ; version < stateVersion; version++ {
+		migrate, ok := stateMigrations[version]
+		if !ok {
+			return state, fmt.Errorf("no migration from version %d", version)
+		}
+		if err := migrate(doc); err != nil {
+			return state, fmt.Errorf("migrate from version %d: %w", version, err)
+		}
+	}
+	doc["version"], _ = json.Marshal(stateVersion)
+	data, _ = json.Marshal(doc)
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+func readState(path string) (BotState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return BotState{}, err
+	}
+	state, err := decodeState(data)
+	if err != nil {
+		return state, fmt.Errorf("%s: %w", path, err)
+	}
+	return state, nil
+}
+
+func (b *Bot) backupFile() string { return b.stateFile + ".bak" }
+
+// loadState restores the processed IDs. A missing file means a first run.
+// A damaged file falls back to the backup of the last good state; if that
+// is unusable too, loading fails rather than starting empty, which would
+// make the bot reply to and vote on everything again.
+func (b *Bot) loadState() error {
+	state, err := readState(b.stateFile)
+	if os.IsNotExist(err) {
+		if _, statErr := os.Stat(b.backupFile()); os.IsNotExist(statErr) {
+			return nil // 文件不存在，使用空状态
+		}
+	}
+	if err != nil {
+		b.logError("🚨 State file is unusable: %v", err)
+		backup, backupErr := readState(b.backupFile())
+		if backupErr != nil {
+			return fmt.Errorf("state file is unusable (%v) and so is its backup (%v); fix or remove them to start from empty state", err, backupErr)
+		}
+		b.logError("🚨 Restored state from backup %s; actions since that save may be repeated", b.backupFile())
+		state = backup
+	}
+	b.applyState(state)
+	return nil
+}
+
+func (b *Bot) applyState(state BotState) {
+	for _, id := range state.ProcessedComments {
+		b.seen.add("comment", id, "")
+	}
+	for _, id := range state.ProcessedPosts {
+		b.seen.add("post", id, "")
+	}
+	for _, id := range state.VotedProjects {
+		b.seen.add("project", id, "")
+	}
+	for _, name := range state.InteractedAgents {
+		b.seen.add("agent", 0, name)
+	}
+	for _, id := range state.OwnPosts {
+		b.seen.add("own_post", id, "")
+	}
+	for _, id := range state.DeclinedPosts {
+		b.seen.add("declined", id, "")
+	}
+	for theirs, ours := range state.Replies {
+		b.seen.replies[theirs] = ours
+	}
+	b.lastProgressPost = state.LastProgressPost
+	b.lastNewPost = state.LastNewPost
+	b.topicIndex = state.TopicIndex
+}
+
+func (b *Bot) snapshotState() BotState {
+	state := BotState{
+		Version:          stateVersion,
+		LastProgressPost: b.lastProgressPost,
+		LastNewPost:      b.lastNewPost,
+		TopicIndex:       b.topicIndex,
+		Replies:          make(map[int]int, len(b.seen.replies)),
+	}
+	for theirs, ours := range b.seen.replies {
+		state.Replies[theirs] = ours
+	}
+	for id := range b.seen.comments {
+		state.ProcessedComments = append(state.ProcessedComments, id)
+	}
+	for id := range b.seen.posts {
+		state.ProcessedPosts = append(state.ProcessedPosts, id)
+	}
+	for id := range b.seen.projects {
+		state.VotedProjects = append(state.VotedProjects, id)
+	}
+	for name := range b.seen.agents {
+		state.InteractedAgents = append(state.InteractedAgents, name)
+	}
+	for id := range b.seen.ownPosts {
+		state.OwnPosts = append(state.OwnPosts, id)
+	}
+	for id := range b.seen.declined {
+		state.DeclinedPosts = append(state.DeclinedPosts, id)
+	}
+	sort.Ints(state.ProcessedComments)
+	sort.Ints(state.ProcessedPosts)
+	sort.Ints(state.VotedProjects)
+	sort.Strings(state.InteractedAgents)
+	sort.Ints(state.OwnPosts)
+	sort.Ints(state.DeclinedPosts)
+	return state
+}
+
+// saveState keeps the current file as the backup (if it is still readable)
+// and atomically replaces it, so a crash never leaves a half-written state.
+// The SQLite backend already stored every entry as it happened and only
+// adds the round stats.
+func (b *Bot) saveState() error {
+	if b.db != nil {
+		return b.recordRound()
+	}
+	data, err := json.MarshalIndent(b.snapshotState(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(b.stateFile); err == nil {
+		if _, err := decodeState(old); err == nil {
+			if err := atomicfile.Write(b.backupFile(), old, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	if err := atomicfile.Write(b.stateFile, data, 0644); err != nil {
+		return err
+	}
+	return b.truncateJournal()
+}
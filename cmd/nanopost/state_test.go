@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// stateBot is a bot with empty state persisted at path.
+func stateBot(path string) *Bot {
+	return &Bot{seen: newSeenSet(), stateFile: path, quiet: true}
+}
+
+func TestDecodeState(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    BotState
+		wantErr bool
+	}{
+		{
+			name: "v1 without version",
+			data: `{"processed_comments":[1,2],"processed_posts":[3],"voted_projects":[4],"interacted_agents":["alice"],"topic_index":2}`,
+			want: BotState{Version: stateVersion, ProcessedComments: []int{1, 2}, ProcessedPosts: []int{3}, VotedProjects: []int{4}, InteractedAgents: []string{"alice"}, TopicIndex: 2},
+		},
+		{
+			name: "v1 empty object",
+			data: `{}`,
+			want: BotState{Version: stateVersion},
+		},
+		{
+			name: "current version",
+			data: `{"version":2,"processed_posts":[7],"own_posts":[8],"replies":{"10":11}}`,
+			want: BotState{Version: 2, ProcessedPosts: []int{7}, OwnPosts: []int{8}, Replies: map[int]int{10: 11}},
+		},
+		{name: "newer version", data: `{"version":3}`, wantErr: true},
+		{name: "bad version", data: `{"version":"two"}`, wantErr: true},
+		{name: "not an object", data: `null`, wantErr: true},
+		{name: "torn write", data: `{"processed_comments":[1,`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeState([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeState error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeState = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadStateBackup(t *testing.T) {
+	const good = `{"version":2,"processed_comments":[42]}`
+	tests := []struct {
+		name        string
+		state       string // "" = no file
+		backup      string // "" = no file
+		wantErr     bool
+		wantComment bool // comment 42 is marked handled
+	}{
+		{name: "first run"},
+		{name: "good state", state: good, backup: `garbage`, wantComment: true},
+		{name: "damaged state, good backup", state: `{"version":2,`, backup: good, wantComment: true},
+		{name: "missing state, good backup", backup: good, wantComment: true},
+		{name: "both damaged", state: `{`, backup: `{`, wantErr: true},
+		{name: "damaged state, no backup", state: `{`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := stateBot(filepath.Join(t.TempDir(), "state.json"))
+			for path, data := range map[string]string{b.stateFile: tt.state, b.backupFile(): tt.backup} {
+				if data != "" {
+					if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			err := b.loadState()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadState error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := b.seen.has("comment", 42, ""); got != tt.wantComment {
+				t.Errorf("comment 42 handled = %v, want %v", got, tt.wantComment)
+			}
+		})
+	}
+}
+
+func TestSaveStateKeepsBackup(t *testing.T) {
+	b := stateBot(filepath.Join(t.TempDir(), "state.json"))
+	b.seen.add("post", 1, "")
+	if err := b.saveState(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(b.backupFile()); !os.IsNotExist(err) {
+		t.Errorf("first save made a backup (stat: %v)", err)
+	}
+	b.seen.add("post", 2, "")
+	if err := b.saveState(); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := readState(b.backupFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backup.ProcessedPosts, []int{1}) {
+		t.Errorf("backup posts = %v, want the previous save [1]", backup.ProcessedPosts)
+	}
+
+	// A damaged state file must not replace a good backup.
+	os.WriteFile(b.stateFile, []byte("{"), 0644)
+	if err := b.saveState(); err != nil {
+		t.Fatal(err)
+	}
+	if backup, _ = readState(b.backupFile()); !reflect.DeepEqual(backup.ProcessedPosts, []int{1}) {
+		t.Errorf("backup posts = %v after saving over a damaged file, want [1] kept", backup.ProcessedPosts)
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	b := stateBot("")
+	b.applyState(BotState{
+		ProcessedComments: []int{3, 1},
+		ProcessedPosts:    []int{2},
+		VotedProjects:     []int{9},
+		InteractedAgents:  []string{"bob", "alice"},
+		OwnPosts:          []int{186},
+		Replies:           map[int]int{1: 555},
+		DeclinedPosts:     []int{40, 12},
+		TopicIndex:        4,
+	})
+	want := BotState{
+		Version:           stateVersion,
+		ProcessedComments: []int{1, 3},
+		ProcessedPosts:    []int{2},
+		VotedProjects:     []int{9},
+		InteractedAgents:  []string{"alice", "bob"},
+		OwnPosts:          []int{186},
+		Replies:           map[int]int{1: 555},
+		DeclinedPosts:     []int{12, 40},
+		TopicIndex:        4,
+	}
+	if got := b.snapshotState(); !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshotState = %+v, want %+v", got, want)
+	}
+}
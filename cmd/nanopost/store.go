@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"nanopost/internal/history"
+)
+
+// ==================== Store ====================
+//
+// With state.backend: json (the default) the handled IDs live in memory,
+// in the state file and its journal. With state.backend: sqlite they are
+// rows in an embedded database, looked up per query, and every reply,
+// vote, post, tweet and round is kept there as searchable history.
+
+// seenSet holds handled IDs in memory: the whole state for the JSON
+// backend, and only this run's dry-run entries for SQLite.
+type seenSet struct {
+	comments, posts, projects map[int]bool
+	agents                    map[string]bool
+	ownPosts                  map[int]bool // posts we created; see ownposts.go
+	declined                  map[int]bool // posts the engagement judge said no to; see judge.go
+	replies                   map[int]int  // their comment -> our reply; see threading.go
+}
+
+func newSeenSet() *seenSet {
+	return &seenSet{
+		comments: make(map[int]bool),
+		posts:    make(map[int]bool),
+		projects: make(map[int]bool),
+		agents:   make(map[string]bool),
+		ownPosts: make(map[int]bool),
+		declined: make(map[int]bool),
+		replies:  make(map[int]int),
+	}
+}
+
+func (s *seenSet) add(kind string, id int, name string) {
+	switch kind {
+	case "comment":
+		s.comments[id] = true
+	case "post":
+		s.posts[id] = true
+	case "project":
+		s.projects[id] = true
+	case "agent":
+		s.agents[name] = true
+	case "own_post":
+		s.ownPosts[id] = true
+	case "declined":
+		s.declined[id] = true
+	}
+}
+
+func (s *seenSet) has(kind string, id int, name string) bool {
+	switch kind {
+	case "comment":
+		return s.comments[id]
+	case "post":
+		return s.posts[id]
+	case "project":
+		return s.projects[id]
+	case "agent":
+		return s.agents[name]
+	case "own_post":
+		return s.ownPosts[id]
+	case "declined":
+		return s.declined[id]
+	}
+	return false
+}
+
+// done reports whether a comment, post, project or agent was already
+// handled. A failed database lookup counts as handled, so a broken disk
+// never makes the bot repeat itself.
+func (b *Bot) done(kind string, id int, name string) bool {
+	if b.seen.has(kind, id, name) {
+		return true
+	}
+	if b.db == nil {
+		return false
+	}
+	ok, err := b.db.Seen(kind, id, name)
+	if err != nil {
+		b.logError("❌ State lookup failed, skipping %s %d%s: %v", kind, id, name, err)
+		return true
+	}
+	return ok
+}
+
+// record counts a successful write in the metrics and adds it to the
+// history database (sqlite backend only). It does nothing in dry runs.
+func (b *Bot) record(e history.Entry) {
+	if b.dryRun {
+		return
+	}
+	metricInteractions.Inc(b.cfg.Agent.Name, e.Kind)
+	if b.db == nil {
+		return
+	}
+	if e.Detail == "" {
+		e.Detail = b.rule
+	}
+	if err := b.db.Add(e); err != nil {
+		b.logError("❌ Failed to write history: %v", err)
+	}
+}
+
+// Meta keys for the state that is not a set of IDs.
+const (
+	metaLastProgressPost = "last_progress_post"
+	metaLastNewPost      = "last_new_post"
+	metaTopicIndex       = "topic_index"
+)
+
+// storeEntry writes a journal entry to the database instead of the journal.
+func (b *Bot) storeEntry(e journalEntry) error {
+	switch e.Kind {
+	case "progress":
+		return b.db.Set(metaLastProgressPost, e.Time.Format(time.RFC3339Nano))
+	case "new_post":
+		if err := b.db.Set(metaLastNewPost, e.Time.Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+		return b.db.Set(metaTopicIndex, strconv.Itoa(e.ID))
+	case "reply":
+		return nil // the reply's history entry has both comment IDs
+	}
+	return b.db.MarkSeen(e.Kind, e.ID, e.Name, e.Time)
+}
+
+// openHistory switches the bot to the SQLite backend. A new database is
+// seeded from the JSON state file and journal, if there are any, so
+// changing backends does not forget what was already handled.
+func (b *Bot) openHistory(path string) error {
+	db, err := history.Open(path)
+	if err != nil {
+		return err
+	}
+	empty, err := db.Empty()
+	if err != nil {
+		db.Close()
+		return err
+	}
+	if empty {
+		if err := b.importState(db); err != nil {
+			db.Close()
+			return fmt.Errorf("import %s into %s: %w", b.stateFile, path, err)
+		}
+	}
+	b.db = db
+	b.seen = newSeenSet()
+	return b.loadMeta()
+}
+
+func (b *Bot) importState(db *history.DB) error {
+	if err := b.loadState(); err != nil {
+		return err
+	}
+	if err := b.replayJournal(); err != nil {
+		return err
+	}
+	state := b.snapshotState()
+	now := time.Now()
+	for _, id := range state.ProcessedComments {
+		if err := db.MarkSeen("comment", id, "", now); err != nil {
+			return err
+		}
+	}
+	for _, id := range state.ProcessedPosts {
+		if err := db.MarkSeen("post", id, "", now); err != nil {
+			return err
+		}
+	}
+	for _, id := range state.VotedProjects {
+		if err := db.MarkSeen("project", id, "", now); err != nil {
+			return err
+		}
+	}
+	for _, name := range state.InteractedAgents {
+		if err := db.MarkSeen("agent", 0, name, now); err != nil {
+			return err
+		}
+	}
+	for _, id := range state.OwnPosts {
+		if err := db.MarkSeen("own_post", id, "", now); err != nil {
+			return err
+		}
+	}
+	for _, id := range state.DeclinedPosts {
+		if err := db.MarkSeen("declined", id, "", now); err != nil {
+			return err
+		}
+	}
+	if !state.LastProgressPost.IsZero() {
+		if err := db.Set(metaLastProgressPost, state.LastProgressPost.Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+	}
+	if !state.LastNewPost.IsZero() {
+		if err := db.Set(metaLastNewPost, state.LastNewPost.Format(time.RFC3339Nano)); err != nil {
+			return err
+		}
+	}
+	if err := db.Set(metaTopicIndex, strconv.Itoa(state.TopicIndex)); err != nil {
+		return err
+	}
+	if n := len(state.ProcessedComments) + len(state.ProcessedPosts) + len(state.VotedProjects) + len(state.InteractedAgents) + len(state.OwnPosts) + len(state.DeclinedPosts); n > 0 {
+		b.log("🗄️ Imported %d handled IDs from %s into %s", n, b.stateFile, db.Path())
+	}
+	return nil
+}
+
+// loadMeta restores the post times and topic index from the database.
+func (b *Bot) loadMeta() error {
+	for key, dst := range map[string]*time.Time{metaLastProgressPost: &b.lastProgressPost, metaLastNewPost: &b.lastNewPost} {
+		value, ok, err := b.db.Get(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if *dst, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return fmt.Errorf("history: bad %s %q: %w", key, value, err)
+			}
+		}
+	}
+	value, ok, err := b.db.Get(metaTopicIndex)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if b.topicIndex, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("history: bad %s %q: %w", metaTopicIndex, value, err)
+		}
+	}
+	return nil
+}
+
+// recordRound stores the round stats; see saveState.
+func (b *Bot) recordRound() error {
+	stats, err := json.Marshal(b.roundStats)
+	if err != nil {
+		return err
+	}
+	s := b.roundStats
+	return b.db.AddRound(history.Round{
+		Replies:         s.RepliesCount,
+		Votes:           s.VotesCount,
+		ProjectVotes:    s.ProjectVotesCount,
+		Engagements:     s.EngagementsCount,
+		Mentions:        s.Mentions,
+		LeaderboardRank: s.LeaderboardRank,
+		Stats:           string(stats),
+	})
+}
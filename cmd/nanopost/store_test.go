@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenHistoryImportsJSONState(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	dbFile := filepath.Join(dir, "history.db")
+	os.WriteFile(stateFile, []byte(`{"version":2,"processed_comments":[1],"voted_projects":[9],"declined_posts":[12],"topic_index":3}`), 0644)
+	os.WriteFile(stateFile+".journal", []byte(`{"kind":"agent","name":"alice"}`+"\n"), 0644)
+
+	b := stateBot(stateFile)
+	if err := b.openHistory(dbFile); err != nil {
+		t.Fatal(err)
+	}
+	defer b.db.Close()
+	if len(b.seen.comments) != 0 {
+		t.Errorf("in-memory set = %+v, want it emptied once the database takes over", b.seen)
+	}
+	for _, c := range []struct {
+		kind string
+		id   int
+		name string
+	}{{"comment", 1, ""}, {"project", 9, ""}, {"declined", 12, ""}, {"agent", 0, "alice"}} {
+		if !b.done(c.kind, c.id, c.name) {
+			t.Errorf("%s %d%s not imported", c.kind, c.id, c.name)
+		}
+	}
+	if b.done("comment", 2, "") {
+		t.Error("comment 2 reported as handled")
+	}
+	if b.topicIndex != 3 {
+		t.Errorf("topicIndex = %d, want 3", b.topicIndex)
+	}
+}
+
+func TestOpenHistoryImportsOnlyOnce(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	dbFile := filepath.Join(dir, "history.db")
+	os.WriteFile(stateFile, []byte(`{"version":2,"processed_posts":[5]}`), 0644)
+
+	first := stateBot(stateFile)
+	if err := first.openHistory(dbFile); err != nil {
+		t.Fatal(err)
+	}
+	first.db.Close()
+
+	// Whatever the JSON file says later, a database in use is not reseeded.
+	os.WriteFile(stateFile, []byte(`{"version":2,"processed_posts":[6]}`), 0644)
+	second := stateBot(stateFile)
+	if err := second.openHistory(dbFile); err != nil {
+		t.Fatal(err)
+	}
+	defer second.db.Close()
+	if !second.done("post", 5, "") || second.done("post", 6, "") {
+		t.Errorf("post 5 handled = %v, post 6 handled = %v; want only 5", second.done("post", 5, ""), second.done("post", 6, ""))
+	}
+}
+
+func TestOpenHistoryRefusesDamagedState(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	os.WriteFile(stateFile, []byte(`{"version":`), 0644)
+	b := stateBot(stateFile)
+	if err := b.openHistory(filepath.Join(dir, "history.db")); err == nil {
+		b.db.Close()
+		t.Fatal("openHistory imported a damaged state file")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"nanopost/config"
+)
+
+// ==================== Round Summary ====================
+
+// SummaryConfig selects how the per-round summary is written.
+type SummaryConfig struct {
+	Language     string `yaml:"language"`      // en | zh, or any language added in the template file
+	Format       string `yaml:"format"`        // markdown | json | html
+	TemplateFile string `yaml:"template_file"` // relative to the config directory; empty = built-in templates
+}
+
+// SummaryTemplates is the template file: one template per text format and
+// message catalogs that add to or override the built-in ones.
+type SummaryTemplates struct {
+	Markdown string                       `yaml:"markdown"`
+	HTMLHead string                       `yaml:"html_head"` // starts each new html file
+	HTML     string                       `yaml:"html"`      // appended each round
+	Messages map[string]map[string]string `yaml:"messages"`
+}
+
+// SummaryData is what the templates see; json writes it as one line.
+type SummaryData struct {
+	Time     time.Time         `json:"time"`
+	Agent    string            `json:"agent"`
+	Language string            `json:"-"`
+	Stats    RoundStats        `json:"stats"`
+	M        map[string]string `json:"-"` // messages in the chosen language
+}
+
+// builtinSummary is config/summary.yaml as compiled in: the default
+// templates and the en/zh messages.
+var builtinSummary = func() SummaryTemplates {
+	var t SummaryTemplates
+	if err := yaml.Unmarshal(config.SummaryYAML, &t); err != nil {
+		panic(fmt.Sprintf("built-in summary.yaml: %v", err))
+	}
+	return t
+}()
+
+var summaryFuncs = map[string]interface{}{
+	"join": strings.Join,
+	"cell": strings.NewReplacer("|", `\|`, "\n", " ").Replace, // text inside a Markdown table cell
+}
+
+// defaultSummaryTemplates returns the built-in templates. Messages are
+// left out: the renderer always starts from the built-in catalogs.
+func defaultSummaryTemplates() SummaryTemplates {
+	return SummaryTemplates{Markdown: builtinSummary.Markdown, HTMLHead: builtinSummary.HTMLHead, HTML: builtinSummary.HTML}
+}
+
+// readSummaryTemplates reads a template file and checks that its templates
+// compile. Templates it leaves out keep their built-in version.
+func readSummaryTemplates(path string) (SummaryTemplates, error) {
+	var t SummaryTemplates
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return defaultSummaryTemplates(), err
+	}
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return defaultSummaryTemplates(), fmt.Errorf("%s: %w", path, err)
+	}
+	def := defaultSummaryTemplates()
+	for _, f := range []struct{ tmpl, def *string }{{&t.Markdown, &def.Markdown}, {&t.HTMLHead, &def.HTMLHead}, {&t.HTML, &def.HTML}} {
+		if strings.TrimSpace(*f.tmpl) == "" {
+			*f.tmpl = *f.def
+		}
+	}
+	if _, err := template.New("markdown").Funcs(summaryFuncs).Parse(t.Markdown); err != nil {
+		return t, fmt.Errorf("%s: markdown: %w", path, err)
+	}
+	if _, err := htmltemplate.New("html").Funcs(summaryFuncs).Parse(t.HTMLHead + t.HTML); err != nil {
+		return t, fmt.Errorf("%s: html: %w", path, err)
+	}
+	return t, nil
+}
+
+// summaryRenderer renders round summaries in one language and format.
+type summaryRenderer struct {
+	format, language string
+	messages         map[string]string
+	markdown         *template.Template
+	html, htmlHead   *htmltemplate.Template
+}
+
+// newSummaryRenderer compiles the templates and picks the catalog. Missing
+// messages fall back to English.
+func newSummaryRenderer(c SummaryConfig, t SummaryTemplates) (*summaryRenderer, error) {
+	r := &summaryRenderer{format: c.Format, language: c.Language, messages: map[string]string{}}
+	if _, builtin := builtinSummary.Messages[c.Language]; !builtin && t.Messages[c.Language] == nil {
+		return nil, fmt.Errorf("summary.language: no messages for %q (have %s)", c.Language, strings.Join(summaryLanguages(t), ", "))
+	}
+	for _, catalog := range []map[string]string{builtinSummary.Messages["en"], t.Messages["en"], builtinSummary.Messages[c.Language], t.Messages[c.Language]} {
+		for k, v := range catalog {
+			r.messages[k] = v
+		}
+	}
+	var err error
+	switch c.Format {
+	case "markdown":
+		r.markdown, err = template.New("markdown").Funcs(summaryFuncs).Parse(t.Markdown)
+	case "html":
+		if r.htmlHead, err = htmltemplate.New("html_head").Funcs(summaryFuncs).Parse(t.HTMLHead); err == nil {
+			r.html, err = htmltemplate.New("html").Funcs(summaryFuncs).Parse(t.HTML)
+		}
+	case "json":
+	default:
+		err = fmt.Errorf("want markdown, json or html, got %q", c.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("summary %s template: %w", c.Format, err)
+	}
+	return r, nil
+}
+
+func summaryLanguages(t SummaryTemplates) []string {
+	seen := map[string]bool{}
+	for lang := range builtinSummary.Messages {
+		seen[lang] = true
+	}
+	for lang := range t.Messages {
+		seen[lang] = true
+	}
+	var langs []string
+	for lang := range seen {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// head renders what starts a new summary file: the html document head, or
+// nothing for the other formats.
+func (r *summaryRenderer) head(d SummaryData) (string, error) {
+	if r.format != "html" {
+		return "", nil
+	}
+	d.Language, d.M = r.language, r.messages
+	var buf bytes.Buffer
+	err := r.htmlHead.Execute(&buf, d)
+	return buf.String(), err
+}
+
+func (r *summaryRenderer) render(d SummaryData) (string, error) {
+	d.Language, d.M = r.language, r.messages
+	var buf bytes.Buffer
+	var err error
+	switch r.format {
+	case "json":
+		err = json.NewEncoder(&buf).Encode(d)
+	case "html":
+		err = r.html.Execute(&buf, d)
+	default:
+		buf.WriteString("\n")
+		err = r.markdown.Execute(&buf, d)
+	}
+	return buf.String(), err
+}
+
+// summaryPattern is output.summary_file_pattern with the extension matching
+// the format: summary_%s.md becomes summary_%s.html or summary_%s.jsonl.
+func (c Config) summaryPattern() string {
+	p := c.Output.SummaryPattern
+	if !strings.HasSuffix(p, ".md") {
+		return p
+	}
+	switch c.Summary.Format {
+	case "html":
+		return strings.TrimSuffix(p, ".md") + ".html"
+	case "json":
+		return strings.TrimSuffix(p, ".md") + ".jsonl"
+	}
+	return p
+}
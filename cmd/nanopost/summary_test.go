@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRound = SummaryData{
+	Time:  time.Date(2026, 2, 1, 9, 30, 0, 0, time.UTC),
+	Agent: "moltpost-agent",
+	Stats: RoundStats{
+		RepliesCount:     2,
+		RepliedTo:        []string{"alice", "<b>bob</b>"},
+		VotesCount:       3,
+		EngagementsCount: 1,
+		EngagedWith:      []string{"carol", "dave|eve"},
+		NewPostPosted:    true,
+		LeaderboardRank:  7,
+	},
+}
+
+func render(t *testing.T, c SummaryConfig, tmpl SummaryTemplates) string {
+	t.Helper()
+	r, err := newSummaryRenderer(c, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.render(testRound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSummaryMarkdown(t *testing.T) {
+	out := render(t, SummaryConfig{Language: "en", Format: "markdown"}, defaultSummaryTemplates())
+	for _, want := range []string{
+		"## 🕐 09:30:00",
+		"| 💬 Replies | 2 | alice, <b>bob</b> |",
+		`| 🤝 Engagements | 1 | carol, dave\|eve |`, // a | in a name must not split the cell
+		"| 📮 New post | ✅ | Published |",
+		"| 🏆 Rank | #7 | - |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown summary lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Progress") {
+		t.Errorf("progress row rendered although nothing was posted:\n%s", out)
+	}
+
+	zh := render(t, SummaryConfig{Language: "zh", Format: "markdown"}, defaultSummaryTemplates())
+	if !strings.Contains(zh, "| 💬 回复 | 2 |") {
+		t.Errorf("zh summary:\n%s", zh)
+	}
+}
+
+func TestSummaryHTMLEscapes(t *testing.T) {
+	out := render(t, SummaryConfig{Language: "en", Format: "html"}, defaultSummaryTemplates())
+	if strings.Contains(out, "<b>bob</b>") || !strings.Contains(out, "&lt;b&gt;bob&lt;/b&gt;") {
+		t.Errorf("agent names must be escaped in html:\n%s", out)
+	}
+}
+
+func TestSummaryHTMLHead(t *testing.T) {
+	r, err := newSummaryRenderer(SummaryConfig{Language: "zh", Format: "html"}, defaultSummaryTemplates())
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.head(testRound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(head, "<!DOCTYPE html>") || !strings.Contains(head, `<html lang="zh">`) || !strings.Contains(head, `<meta charset="utf-8">`) {
+		t.Errorf("html head:\n%s", head)
+	}
+
+	md, _ := newSummaryRenderer(SummaryConfig{Language: "en", Format: "markdown"}, defaultSummaryTemplates())
+	if head, err := md.head(testRound); head != "" || err != nil {
+		t.Errorf("markdown head = %q, %v; want nothing", head, err)
+	}
+}
+
+func TestSummaryJSON(t *testing.T) {
+	out := render(t, SummaryConfig{Language: "en", Format: "json"}, defaultSummaryTemplates())
+	if strings.Count(out, "\n") != 1 {
+		t.Errorf("json summary should be one line, got %q", out)
+	}
+	var got SummaryData
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Agent != "moltpost-agent" || got.Stats.VotesCount != 3 || got.M != nil {
+		t.Errorf("decoded = %+v", got)
+	}
+}
+
+func TestSummaryCustomLanguage(t *testing.T) {
+	tmpl := defaultSummaryTemplates()
+	tmpl.Messages = map[string]map[string]string{"fr": {"replies": "Réponses"}}
+	out := render(t, SummaryConfig{Language: "fr", Format: "markdown"}, tmpl)
+	if !strings.Contains(out, "| 💬 Réponses | 2 |") || !strings.Contains(out, "| 👍 Post votes | 3 |") {
+		t.Errorf("fr summary should fall back to English where untranslated:\n%s", out)
+	}
+
+	if _, err := newSummaryRenderer(SummaryConfig{Language: "de", Format: "markdown"}, tmpl); err == nil || !strings.Contains(err.Error(), "en, fr, zh") {
+		t.Errorf("unknown language: err = %v, want the available ones listed", err)
+	}
+	if _, err := newSummaryRenderer(SummaryConfig{Language: "en", Format: "pdf"}, tmpl); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestReadSummaryTemplates(t *testing.T) {
+	path := writeTemp(t, "summary.yaml", "markdown: \"{{.Agent}} did {{.Stats.RepliesCount}}\"\n")
+	tmpl, err := readSummaryTemplates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.HTML != builtinSummary.HTML || tmpl.HTMLHead != builtinSummary.HTMLHead {
+		t.Error("a file without html templates should keep the built-in ones")
+	}
+	if out := render(t, SummaryConfig{Language: "en", Format: "markdown"}, tmpl); out != "\nmoltpost-agent did 2" {
+		t.Errorf("custom markdown = %q", out)
+	}
+
+	bad := writeTemp(t, "summary.yaml", "html: \"{{.Agent\"\n")
+	if _, err := readSummaryTemplates(bad); err == nil || !strings.Contains(err.Error(), "html") {
+		t.Errorf("broken html template: err = %v", err)
+	}
+}
+
+func TestSummaryPattern(t *testing.T) {
+	for _, tt := range []struct{ pattern, format, want string }{
+		{"summary_%s.md", "markdown", "summary_%s.md"},
+		{"summary_%s.md", "html", "summary_%s.html"},
+		{"summary_%s.md", "json", "summary_%s.jsonl"},
+		{"rounds_%s.log", "json", "rounds_%s.log"}, // a custom extension is kept
+	} {
+		var c Config
+		c.Output.SummaryPattern, c.Summary.Format = tt.pattern, tt.format
+		if got := c.summaryPattern(); got != tt.want {
+			t.Errorf("%s as %s = %s, want %s", tt.pattern, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"nanopost/internal/colosseum"
+)
+
+// ==================== Threaded Replies ====================
+//
+// A reply is attached to the comment it answers. With
+// conversation.threading: parent it is sent with the API's parentId field;
+// if the API rejects that field, the bot quotes instead until the config is
+// reloaded. With quote, the reply is a top-level comment that starts with an
+// @mention and the quoted comment. Either way, which of our comments
+// answers which of theirs is kept in the state and the history.
+
+// sendReply posts reply as an answer to comment c on a post and returns
+// our comment's ID (0 if unknown).
+func (b *Bot) sendReply(ctx context.Context, postID int, c colosseum.Comment, reply string) (int, error) {
+	mode := b.cfg.Conversation.Threading
+	if mode == "parent" && !b.parentRejected {
+		id, err := b.Comment(ctx, postID, c.ID, reply)
+		if !parentRefused(err) {
+			return id, err
+		}
+		b.logWarn("⚠️ The API refused the reply's parent comment, quoting comments from now on: %v", err)
+		b.parentRejected = true
+	}
+	if mode != "off" {
+		reply = quoteComment(c, reply)
+	}
+	return b.Comment(ctx, postID, 0, reply)
+}
+
+// parentRefused reports whether the API rejected a comment because of its
+// parentId field, rather than, say, its body.
+func parentRefused(err error) bool {
+	var apiErr *colosseum.APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity) {
+		return false
+	}
+	return strings.Contains(strings.ToLower(apiErr.Body), "parent")
+}
+
+// quoteComment starts reply with an @mention of c's author and the first
+// lines of c.
+func quoteComment(c colosseum.Comment, reply string) string {
+	var quote strings.Builder
+	for _, line := range strings.Split(truncate(strings.TrimSpace(c.Body), 300), "\n") {
+		quote.WriteString("> " + line + "\n")
+	}
+	return fmt.Sprintf("@%s wrote:\n%s\n%s", c.AgentName, quote.String(), reply)
+}
+
+// rememberReply records that our comment ours answers their comment
+// theirs. The sqlite backend keeps this in the reply's history entry.
+func (b *Bot) rememberReply(theirs, ours int) {
+	if ours == 0 {
+		return
+	}
+	b.rememberEntry(journalEntry{Kind: "reply", ID: theirs, Ref: ours})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nanopost/internal/colosseum"
+	"nanopost/internal/logfile"
+)
+
+func TestQuoteComment(t *testing.T) {
+	c := colosseum.Comment{AgentName: "alice", Body: "  first line\nsecond line\n"}
+	want := "@alice wrote:\n> first line\n> second line\n\nThanks!"
+	if got := quoteComment(c, "Thanks!"); got != want {
+		t.Errorf("quoteComment =\n%s\nwant\n%s", got, want)
+	}
+
+	long := colosseum.Comment{AgentName: "bob", Body: strings.Repeat("word ", 200)}
+	quoted := strings.SplitN(quoteComment(long, "ok"), "\n", 3)[1]
+	if len(quoted) > 310 || !strings.HasPrefix(quoted, "> word") {
+		t.Errorf("long comments are cut, got a %d byte quote", len(quoted))
+	}
+}
+
+func TestParentRefused(t *testing.T) {
+	apiErr := func(status int, body string) error {
+		return fmt.Errorf("comment: %w", &colosseum.APIError{Method: "POST", Endpoint: "/forum/posts/1/comments", StatusCode: status, Body: body})
+	}
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{apiErr(400, `{"error":"Unknown field: parentId"}`), true},
+		{apiErr(422, `{"errors":{"parent_id":"not a comment on this post"}}`), true},
+		{apiErr(400, `{"error":"body is too long"}`), false},
+		{apiErr(500, `parent service down`), false},
+		{apiErr(404, `no such parent`), false},
+		{errors.New("400 parentId"), false}, // not from the API
+		{nil, false},
+	} {
+		if got := parentRefused(tc.err); got != tc.want {
+			t.Errorf("parentRefused(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+// threadAPI answers comment requests, refusing parentId with status
+// refuse (0 = accept). It records the request bodies.
+func threadAPI(t *testing.T, refuse int) (*Bot, *[]map[string]interface{}) {
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		if _, ok := body["parentId"]; ok && refuse != 0 {
+			http.Error(w, `{"error":"unknown field parentId"}`, refuse)
+			return
+		}
+		w.Write([]byte(`{"comment":{"id":500}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return &Bot{api: colosseum.NewClient(srv.URL, "k", srv.Client()), quiet: true}, &bodies
+}
+
+func TestSendReplyModes(t *testing.T) {
+	theirs := colosseum.Comment{ID: 42, AgentName: "alice", Body: "Is it alive?"}
+	tests := []struct {
+		mode     string
+		wantBody string // prefix of what is sent
+		wantPar  bool
+	}{
+		{"parent", "It is.", true},
+		{"quote", "@alice wrote:\n> Is it alive?", false},
+		{"off", "It is.", false},
+	}
+	for _, tt := range tests {
+		b, bodies := threadAPI(t, 0)
+		b.cfg.Conversation.Threading = tt.mode
+		id, err := b.sendReply(context.Background(), 7, theirs, "It is.")
+		if err != nil || id != 500 {
+			t.Fatalf("%s: id = %d, err = %v", tt.mode, id, err)
+		}
+		sent := (*bodies)[0]
+		_, hasParent := sent["parentId"]
+		if len(*bodies) != 1 || hasParent != tt.wantPar || !strings.HasPrefix(sent["body"].(string), tt.wantBody) {
+			t.Errorf("%s: sent %v", tt.mode, *bodies)
+		}
+	}
+}
+
+func TestSendReplyFallsBackToQuoting(t *testing.T) {
+	b, bodies := threadAPI(t, http.StatusUnprocessableEntity)
+	b.cfg.Conversation.Threading = "parent"
+	theirs := colosseum.Comment{ID: 42, AgentName: "alice", Body: "Hi"}
+
+	if _, err := b.sendReply(context.Background(), 7, theirs, "Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(*bodies) != 2 || !strings.HasPrefix((*bodies)[1]["body"].(string), "@alice wrote:") {
+		t.Fatalf("bodies = %v, want the refused try and a quoted retry", *bodies)
+	}
+	if !b.parentRejected {
+		t.Error("parentRejected not set")
+	}
+	// Until a reload, replies quote straight away.
+	b.sendReply(context.Background(), 7, theirs, "Again")
+	if len(*bodies) != 3 {
+		t.Errorf("%d requests, want one more without a parent", len(*bodies))
+	}
+
+	setup := AgentSetup{Config: defaultConfig(), Summary: defaultSummaryTemplates(), APIKey: "k"}
+	setup.Config.API.LLM.Provider = "ollama"
+	setup.Config.RateLimits.StateFile = ""
+	logOut, err := logfile.Open(filepath.Join(t.TempDir(), "log.txt"), logfile.Policy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logOut.Close()
+	b.logOut = logOut
+	if err := b.configure(setup); err != nil {
+		t.Fatal(err)
+	}
+	if b.parentRejected {
+		t.Error("parentRejected survived a reload")
+	}
+}
+
+func TestSendReplyOtherErrorsAreReturned(t *testing.T) {
+	for status, body := range map[int]string{
+		http.StatusForbidden:  "forbidden",
+		http.StatusBadRequest: `{"error":"body is too long"}`,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, body, status)
+		}))
+		b := &Bot{api: colosseum.NewClient(srv.URL, "k", srv.Client()), quiet: true}
+		b.cfg.Conversation.Threading = "parent"
+		if _, err := b.sendReply(context.Background(), 7, colosseum.Comment{ID: 1}, "x"); !colosseum.IsStatus(err, status) || b.parentRejected {
+			t.Errorf("HTTP %d: err = %v, parentRejected = %v; want the error and parent mode kept", status, err, b.parentRejected)
+		}
+		srv.Close()
+	}
+}
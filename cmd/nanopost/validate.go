@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"nanopost/internal/schedule"
+)
+
+// ==================== Validation ====================
+
+// ConfigIssue is one problem found in config.yaml or prompts.yaml.
+type ConfigIssue struct {
+	File string // file name, or "env NAME" / "flag --key" for overridden values
+	Line int    // 0 if the key is missing and has no parent in the file
+	Path string // dotted yaml path, e.g. bot.max_engagements_per_cycle
+	Msg  string
+}
+
+func (i ConfigIssue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", i.File, i.Path, i.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", i.File, i.Line, i.Path, i.Msg)
+}
+
+// ConfigIssues is returned as an error when validation fails.
+type ConfigIssues []ConfigIssue
+
+func (is ConfigIssues) Error() string {
+	lines := make([]string, len(is))
+	for i, issue := range is {
+		lines[i] = issue.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validator collects issues for one file, resolving yaml paths to lines.
+type validator struct {
+	file    string
+	root    *yaml.Node
+	sources configSources // optional; points issues at the env var or flag that set a value
+	issues  ConfigIssues
+}
+
+func newValidator(file string, data []byte) (*validator, error) {
+	v := &validator{file: file}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(doc.Content) > 0 {
+		v.root = doc.Content[0]
+	}
+	return v, nil
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	issue := ConfigIssue{File: v.file, Path: path, Msg: fmt.Sprintf(format, args...)}
+	switch v.sources[path] {
+	case sourceEnv:
+		issue.File = "env " + envName(path)
+	case sourceFlag:
+		issue.File = "flag --" + path
+	default:
+		issue.Line, _ = v.lookup(path)
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// lookup walks the mapping nodes along path; numeric keys index sequences
+// (agents.1.name). It returns the line of the value (or of the deepest
+// existing parent) and the node when found.
+func (v *validator) lookup(path string) (int, *yaml.Node) {
+	node, line := v.root, 0
+	if node != nil {
+		line = node.Line
+	}
+	for _, key := range strings.Split(path, ".") {
+		if node != nil && node.Kind == yaml.SequenceNode {
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 0 || n >= len(node.Content) {
+				return line, nil
+			}
+			node = node.Content[n]
+			line = node.Line
+			continue
+		}
+		if node == nil || node.Kind != yaml.MappingNode {
+			return line, nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				line = node.Content[i].Line
+				break
+			}
+		}
+		if next == nil {
+			return line, nil
+		}
+		node = next
+	}
+	return line, node
+}
+
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.errorf(path, "required")
+	}
+}
+
+func (v *validator) atLeast(path string, value, lower int) {
+	if value < lower {
+		v.errorf(path, "must be >= %d (got %d)", lower, value)
+	}
+}
+
+func (v *validator) pattern(path, value string) {
+	if value != "" && strings.Count(value, "%s") != 1 {
+		v.errorf(path, "must contain exactly one %%s for the date (got %q)", value)
+	}
+}
+
+func (v *validator) keywords(path string, kws []string) {
+	if len(kws) < 4 {
+		v.errorf(path, "need at least 4 keywords (got %d)", len(kws))
+	}
+	for i, kw := range kws {
+		if strings.TrimSpace(kw) == "" {
+			v.errorf(path, "keyword %d is empty", i+1)
+		} else if kw != strings.ToLower(kw) {
+			v.errorf(path, "keyword %q must be lowercase, posts are matched in lowercase", kw)
+		}
+	}
+}
+
+func (v *validator) result() error {
+	if len(v.issues) == 0 {
+		return nil
+	}
+	return v.issues
+}
+
+// validateConfig checks required fields and ranges of the effective config.
+func validateConfig(file string, data []byte, c Config, sources configSources) error {
+	v, err := newValidator(file, data)
+	if err != nil {
+		return err
+	}
+	v.sources = sources
+	v.required("api.base_url", c.API.BaseURL)
+	if u, err := url.Parse(c.API.BaseURL); c.API.BaseURL != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+		v.errorf("api.base_url", "not an absolute URL: %q", c.API.BaseURL)
+	}
+	if _, err := resolveLLMConfig(c); err != nil {
+		v.errorf("api.llm.provider", "%v", err)
+	}
+	if c.API.LLM.MaxTokens < 0 {
+		v.errorf("api.llm.max_tokens", "must be >= 0")
+	}
+	if t := c.API.LLM.Temperature; t != nil && (*t < 0 || *t > 2) {
+		v.errorf("api.llm.temperature", "must be between 0 and 2 (got %g)", *t)
+	}
+
+	profiles, prefix := c.Agents, "agents.%d"
+	if len(profiles) == 0 {
+		profiles, prefix = []AgentProfile{c.Agent}, "agent"
+	}
+	seen := map[string]bool{}
+	sharedKeywords := false
+	for i, p := range profiles {
+		path := prefix
+		if len(c.Agents) > 0 {
+			path = fmt.Sprintf(prefix, i)
+		}
+		v.required(path+".name", p.Name)
+		if strings.ContainsAny(p.Name, `/\ `) {
+			v.errorf(path+".name", "must not contain slashes or spaces, it is used in file names (got %q)", p.Name)
+		}
+		if seen[p.Name] {
+			v.errorf(path+".name", "duplicate agent name %q", p.Name)
+		}
+		seen[p.Name] = true
+		v.atLeast(path+".post_id", p.PostID, 0) // 0 = no pinned post, only our own
+		v.atLeast(path+".project_id", p.ProjectID, 1)
+		if len(p.Keywords) > 0 {
+			v.keywords(path+".keywords", p.Keywords)
+		} else {
+			sharedKeywords = true
+		}
+	}
+
+	v.atLeast("bot.default_interval_minutes", c.Bot.DefaultInterval, 1)
+	v.atLeast("bot.max_engagements_per_cycle", c.Bot.MaxEngagements, 1)
+	v.atLeast("bot.watch_own_posts", c.Bot.WatchOwnPosts, 0)
+
+	if sharedKeywords {
+		v.keywords("keywords", c.Keywords)
+	}
+	for _, m := range []struct {
+		path    string
+		weights map[string]float64
+	}{{"relevance.weights", c.Relevance.Weights}, {"relevance.negative", c.Relevance.Negative}} {
+		for _, kw := range sortedKeys(m.weights) {
+			if strings.TrimSpace(kw) == "" || len(words(kw)) == 0 {
+				v.errorf(m.path, "keyword %q has no words", kw)
+			} else if kw != strings.ToLower(kw) {
+				v.errorf(m.path, "keyword %q must be lowercase, posts are matched in lowercase", kw)
+			}
+			if m.weights[kw] <= 0 {
+				v.errorf(m.path+"."+kw, "must be positive (got %g)", m.weights[kw])
+			}
+		}
+	}
+	if c.Relevance.VoteThreshold < 0 {
+		v.errorf("relevance.vote_threshold", "must be at least 0 (got %g)", c.Relevance.VoteThreshold)
+	}
+	if c.Relevance.EngageThreshold < 0 {
+		v.errorf("relevance.engage_threshold", "must be at least 0 (got %g)", c.Relevance.EngageThreshold)
+	}
+	if c.Relevance.Embedding.Enabled {
+		v.required("relevance.embedding.topic", c.Relevance.Embedding.Topic)
+		if c.Relevance.Embedding.Weight <= 0 {
+			v.errorf("relevance.embedding.weight", "must be positive (got %g)", c.Relevance.Embedding.Weight)
+		}
+	}
+
+	v.atLeast("posting.interval_minutes", c.Posting.Interval, 0)
+	if c.Posting.Enabled && len(c.Posting.Topics) == 0 {
+		v.errorf("posting.topics", "required when posting is enabled")
+	}
+
+	v.required("progress.hackathon_start_date", c.Progress.StartDate)
+	if _, err := time.Parse("2006-01-02", c.Progress.StartDate); c.Progress.StartDate != "" && err != nil {
+		v.errorf("progress.hackathon_start_date", "want YYYY-MM-DD, got %q", c.Progress.StartDate)
+	}
+
+	for _, rl := range []struct {
+		path string
+		rl   RateLimitConfig
+	}{
+		{"rate_limits.vote", c.RateLimits.Vote}, {"rate_limits.comment", c.RateLimits.Comment},
+		{"rate_limits.post", c.RateLimits.Post}, {"rate_limits.llm", c.RateLimits.LLM},
+	} {
+		if rl.rl.IntervalSeconds < 0 {
+			v.errorf(rl.path+".interval_seconds", "must be >= 0")
+		}
+		v.atLeast(rl.path+".burst", rl.rl.Burst, 0)
+		v.atLeast(rl.path+".daily_cap", rl.rl.DailyCap, 0)
+	}
+	v.atLeast("retry.max_time_per_heartbeat_seconds", c.Retry.MaxHeartbeatSeconds, 0)
+	for _, rc := range []struct {
+		path string
+		rc   RetryConfig
+	}{{"retry.read", c.Retry.Read}, {"retry.write", c.Retry.Write}, {"retry.llm", c.Retry.LLM}} {
+		v.atLeast(rc.path+".max_attempts", rc.rc.MaxAttempts, 0)
+		v.atLeast(rc.path+".base_delay_ms", rc.rc.BaseDelayMs, 0)
+		v.atLeast(rc.path+".max_delay_seconds", rc.rc.MaxDelaySeconds, 0)
+	}
+
+	if _, err := c.scheduleLocation(); err != nil {
+		v.errorf("schedule.timezone", "unknown time zone %q", c.Schedule.Timezone)
+	}
+	if _, err := schedule.ParseWindow(c.Schedule.QuietHours); err != nil {
+		v.errorf("schedule.quiet_hours", "%v", err)
+	}
+	for _, name := range heartbeatActions {
+		as, key := c.Schedule.action(name)
+		path := "schedule." + key
+		v.atLeast(path+".interval_minutes", as.IntervalMinutes, 0)
+		v.atLeast(path+".jitter_seconds", as.JitterSeconds, 0)
+		if _, err := schedule.Parse(as.Cron, time.Minute); err != nil {
+			v.errorf(path+".cron", "%v", err)
+		}
+		if _, err := schedule.ParseWindow(as.QuietHours); err != nil {
+			v.errorf(path+".quiet_hours", "%v", err)
+		}
+	}
+
+	switch c.State.Backend {
+	case "json":
+	case "sqlite":
+		v.required("state.sqlite_file", c.State.SQLiteFile)
+	default:
+		v.errorf("state.backend", "want json or sqlite, got %q", c.State.Backend)
+	}
+
+	v.required("output.log_file", c.Output.LogFile)
+	v.required("output.tweet_file_pattern", c.Output.TweetPattern)
+	v.required("output.summary_file_pattern", c.Output.SummaryPattern)
+	v.pattern("output.tweet_file_pattern", c.Output.TweetPattern)
+	v.pattern("output.summary_file_pattern", c.Output.SummaryPattern)
+	v.pattern("output.dry_run_report_pattern", c.Output.DryRunPattern)
+	if _, err := parseDayStart(c.Output.DayStartsAt); err != nil {
+		v.errorf("output.day_starts_at", "%v", err)
+	}
+
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		v.errorf("logging.level", "want debug, info, warn or error, got %q", c.Logging.Level)
+	}
+	if c.Logging.Format != "text" && c.Logging.Format != "json" {
+		v.errorf("logging.format", "want text or json, got %q", c.Logging.Format)
+	}
+	switch c.Logging.Rotate {
+	case "size":
+		v.atLeast("logging.max_size_mb", c.Logging.MaxSizeMB, 1)
+	case "daily", "none":
+	default:
+		v.errorf("logging.rotate", "want size, daily or none, got %q", c.Logging.Rotate)
+	}
+	v.atLeast("logging.max_files", c.Logging.MaxFiles, 0)
+	v.atLeast("logging.max_age_days", c.Logging.MaxAgeDays, 0)
+
+	if c.Control.Enabled {
+		if _, _, err := net.SplitHostPort(c.Control.Listen); err != nil {
+			v.errorf("control.listen", "want host:port, got %q", c.Control.Listen)
+		} else if !isLoopback(c.Control.Listen) && (c.Control.TokenEnv == "" || os.Getenv(c.Control.TokenEnv) == "") {
+			v.errorf("control.listen", "%s is reachable from other hosts; set control.token_env and export the token first", c.Control.Listen)
+		}
+	}
+
+	v.atLeast("conversation.context_tokens", c.Conversation.ContextTokens, 0)
+	v.atLeast("conversation.past_exchanges", c.Conversation.PastExchanges, 0)
+	switch c.Conversation.Threading {
+	case "parent", "quote", "off":
+	default:
+		v.errorf("conversation.threading", "want parent, quote or off, got %q", c.Conversation.Threading)
+	}
+
+	if c.Judge.MinConfidence < 0 || c.Judge.MinConfidence > 1 {
+		v.errorf("judge.min_confidence", "want 0 to 1, got %g", c.Judge.MinConfidence)
+	}
+
+	v.required("summary.language", c.Summary.Language)
+	switch c.Summary.Format {
+	case "markdown", "json", "html":
+	default:
+		v.errorf("summary.format", "want markdown, json or html, got %q", c.Summary.Format)
+	}
+	return v.result()
+}
+
+// templateLine matches the line number in text/template parse errors, e.g.
+// "template: reply:3: unexpected ..." or "... action started at reply:2".
+var (
+	templateLine    = regexp.MustCompile(`^template: [^:]+:(\d+):`)
+	templateStarted = regexp.MustCompile(`started at [^:]+:(\d+)`)
+)
+
+// validatePrompts checks that the prompts needed at runtime are present and
+// that every template compiles.
+func validatePrompts(file string, data []byte, p Prompts) error {
+	v, err := newValidator(file, data)
+	if err != nil {
+		return err
+	}
+	v.required("system", p.System)
+	v.required("reply", p.Reply)
+	v.required("fallback_reply", p.FallbackReply)
+	for _, t := range []struct{ key, text string }{
+		{"tweet", p.Tweet}, {"reply", p.Reply}, {"comment", p.Comment},
+		{"new_post", p.NewPost}, {"progress", p.Progress}, {"fallback_reply", p.FallbackReply},
+		{"judge", p.Judge},
+	} {
+		if _, err := template.New(t.key).Parse(t.text); err != nil {
+			issue := ConfigIssue{File: file, Path: t.key, Msg: err.Error()}
+			issue.Line, _ = v.lookup(t.key)
+			// Block scalars (`key: |`) start on the line after the key.
+			m := templateStarted.FindStringSubmatch(err.Error())
+			if m == nil {
+				m = templateLine.FindStringSubmatch(err.Error())
+			}
+			if m != nil {
+				if n, convErr := strconv.Atoi(m[1]); convErr == nil {
+					issue.Line += n
+				}
+			}
+			v.issues = append(v.issues, issue)
+		}
+	}
+	return v.result()
+}
+
+// runValidate implements `nanopost validate`: it checks config.yaml, the
+// summary template file and every agent's prompts file and prints each issue with its line number.
+// It returns the exit code.
+func runValidate(dir string, overrides map[string]string) int {
+	configPath := filepath.Join(dir, "config.yaml")
+	c, _, err := readConfig(configPath, overrides)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("✅ %s\n", configPath)
+	profiles := c.Agents
+	if len(profiles) == 0 {
+		profiles = []AgentProfile{c.Agent}
+	}
+	ok := true
+	summary := defaultSummaryTemplates()
+	if c.Summary.TemplateFile != "" {
+		summaryPath := filepath.Join(dir, c.Summary.TemplateFile)
+		if summary, err = readSummaryTemplates(summaryPath); err != nil {
+			fmt.Println(err)
+			ok = false
+		} else {
+			fmt.Printf("✅ %s\n", summaryPath)
+		}
+	}
+	if _, err := newSummaryRenderer(c.Summary, summary); err != nil && ok {
+		fmt.Println(err)
+		ok = false
+	}
+	checked := map[string]bool{}
+	for _, p := range profiles {
+		promptsPath := filepath.Join(dir, c.forAgent(p, false).Agent.PromptsFile)
+		if checked[promptsPath] {
+			continue
+		}
+		checked[promptsPath] = true
+		if _, err := readPrompts(promptsPath); err != nil {
+			fmt.Println(err)
+			ok = false
+		} else {
+			fmt.Printf("✅ %s\n", promptsPath)
+		}
+	}
+	if !ok {
+		return 1
+	}
+	return 0
+}
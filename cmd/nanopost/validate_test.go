@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTemp writes content to name in a fresh temp dir and returns the path.
+func writeTemp(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// issueLines indexes issues by path, keeping the line of each.
+func issueLines(t *testing.T, err error) map[string]int {
+	var issues ConfigIssues
+	if !errors.As(err, &issues) {
+		t.Fatalf("err = %v, want ConfigIssues", err)
+	}
+	lines := map[string]int{}
+	for _, is := range issues {
+		lines[is.Path] = is.Line
+	}
+	return lines
+}
+
+func TestValidateConfigLines(t *testing.T) {
+	path := writeTemp(t, "config.yaml", `api:
+  base_url: agents.example/api
+agent:
+  name: bot
+  post_id: 1
+  project_id: 2
+bot:
+  default_interval_minutes: 0
+  max_engagements_per_cycle: 2
+keywords: [human, Agent, identity, social]
+progress:
+  hackathon_start_date: 2026-02-30
+output:
+  log_file: log.txt
+  tweet_file_pattern: tweets.md
+  summary_file_pattern: summary_%s.md
+`)
+	_, _, err := readConfig(path, nil)
+	got := issueLines(t, err)
+	want := map[string]int{
+		"api.base_url":                  2,
+		"bot.default_interval_minutes":  8,
+		"keywords":                      10,
+		"progress.hackathon_start_date": 12,
+		"output.tweet_file_pattern":     15,
+	}
+	for p, line := range want {
+		if got[p] != line {
+			t.Errorf("%s: line %d, want %d (issues: %v)", p, got[p], line, err)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d issue paths, want %d:\n%v", len(got), len(want), err)
+	}
+	if !strings.HasPrefix(err.Error(), path+":2: api.base_url: not an absolute URL") {
+		t.Errorf("first issue = %q", strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+}
+
+func TestValidateConfigMissingKeyPointsAtParent(t *testing.T) {
+	path := writeTemp(t, "config.yaml", "agent:\n  post_id: 1\n  project_id: 1\nprogress:\n  tags: [ai]\n")
+	_, _, err := readConfig(path, nil)
+	got := issueLines(t, err)
+	if line, ok := got["progress.hackathon_start_date"]; !ok || line != 4 {
+		t.Errorf("progress.hackathon_start_date: line %d, want 4 (the progress: key)", line)
+	}
+}
+
+func TestValidatePostIDIsOptional(t *testing.T) {
+	for postID, wantIssue := range map[string]bool{"0": false, "186": false, "-1": true} {
+		path := writeTemp(t, "config.yaml", "agent:\n  post_id: "+postID+"\n  project_id: 1\n")
+		_, _, err := readConfig(path, nil)
+		var issues ConfigIssues
+		errors.As(err, &issues)
+		got := false
+		for _, is := range issues {
+			got = got || is.Path == "agent.post_id"
+		}
+		if got != wantIssue {
+			t.Errorf("post_id %s: issue reported = %v, want %v (err: %v)", postID, got, wantIssue, err)
+		}
+	}
+}
+
+func TestValidatePromptsTemplateLine(t *testing.T) {
+	path := writeTemp(t, "prompts.yaml", `system: hi
+fallback_reply: thanks
+reply: |
+  Reply to {{.Author}}.
+  {{shout .Body}}
+  Thanks.
+`)
+	_, err := readPrompts(path)
+	got := issueLines(t, err)
+	// The bad call is on the second line of the block, which begins after line 3.
+	if got["reply"] != 5 {
+		t.Errorf("reply: line %d, want 5 (%v)", got["reply"], err)
+	}
+}
@@ -0,0 +1,9 @@
+// Package config ships the default configuration files. summary.yaml is
+// compiled in as the built-in round summary templates and messages, so the
+// file and the defaults can't drift apart.
+package config
+
+import _ "embed"
+
+//go:embed summary.yaml
+var SummaryYAML []byte
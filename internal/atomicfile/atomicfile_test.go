@@ -0,0 +1,60 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []byte // nil = no file yet
+		data     []byte
+		perm     os.FileMode
+	}{
+		{"new file", nil, []byte(`{"a":1}`), 0644},
+		{"replaces", []byte("old content that is longer"), []byte("new"), 0644},
+		{"empty data", []byte("old"), []byte{}, 0644},
+		{"private perm", nil, []byte("secret"), 0600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "state.json")
+			if tt.existing != nil {
+				if err := os.WriteFile(path, tt.existing, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := Write(path, tt.data, tt.perm); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(tt.data) {
+				t.Errorf("content = %q, want %q", got, tt.data)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.perm {
+				t.Errorf("perm = %v, want %v", info.Mode().Perm(), tt.perm)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 {
+				t.Errorf("dir has %d entries, want only the target (temp file left behind?)", len(entries))
+			}
+		})
+	}
+}
+
+func TestWriteMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "state.json")
+	if err := Write(path, []byte("x"), 0644); err == nil {
+		t.Fatal("Write into a missing directory succeeded")
+	}
+}
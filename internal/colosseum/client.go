@@ -0,0 +1,320 @@
+// Package colosseum is a typed client for the Colosseum Agent Hackathon API.
+package colosseum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"nanopost/internal/retry"
+)
+
+// APIError is returned for any non-2xx response.
+type APIError struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Body       string
+	retryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	body := e.Body
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+	return fmt.Sprintf("colosseum: %s %s: HTTP %d: %s", e.Method, e.Endpoint, e.StatusCode, body)
+}
+
+// Retryable reports whether the status is transient (429 or 5xx gateway errors).
+func (e *APIError) Retryable() bool { return retry.TemporaryStatus(e.StatusCode) }
+
+// RetryAfter is the delay requested by the server's Retry-After header.
+func (e *APIError) RetryAfter() time.Duration { return e.retryAfter }
+
+// IsStatus reports whether err is an APIError with the given status code.
+func IsStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// Limiter paces write actions by kind: "vote", "comment" or "post". After
+// a Wait without error, Done says whether the write succeeded.
+type Limiter interface {
+	Wait(ctx context.Context, kind string) error
+	Done(kind string, ok bool)
+}
+
+type Client struct {
+	BaseURL string
+	APIKey  string
+	HTTP    *http.Client
+	Read    retry.Policy // GET requests
+	Write   retry.Policy // POST requests; only retried when the server refused them (429/503)
+	Limiter Limiter      // optional; consulted once per write before it is sent
+	Observe Observer     // optional; told about every attempt, for metrics
+}
+
+// Observer receives the outcome of one HTTP attempt. route is the endpoint
+// without its query and with numeric IDs replaced by ":id", so it can be
+// used as a metric label. status is 0 when no response arrived.
+type Observer func(method, route string, status int, elapsed time.Duration)
+
+// route turns "/forum/posts/186/comments?sort=new" into "/forum/posts/:id/comments".
+func route(endpoint string) string {
+	path, _, _ := strings.Cut(endpoint, "?")
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if _, err := strconv.Atoi(p); err == nil {
+			parts[i] = ":id"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func NewClient(baseURL, apiKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{BaseURL: baseURL, APIKey: apiKey, HTTP: httpClient}
+}
+
+// do sends a request with the read or write retry policy and decodes the
+// JSON response into out (if non-nil). Transport failures, non-2xx statuses
+// and malformed JSON from a GET are all errors; a write the server accepted
+// succeeds even if its response can't be decoded. Cancelling ctx aborts the
+// request and any wait before a retry.
+func (c *Client) do(ctx context.Context, method, endpoint string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("colosseum: %s %s: encode request: %w", method, endpoint, err)
+		}
+		payload = data
+	}
+	policy := c.Read
+	if method != "GET" {
+		// A write that timed out or hit a 502 may already have been applied,
+		// so repeating it could double-post. Only retry explicit refusals.
+		policy = c.Write
+		policy.ShouldRetry = refused
+	}
+	return policy.Do(ctx, func() error { return c.send(ctx, method, endpoint, payload, out) })
+}
+
+// write waits for the limiter before sending a POST. Only a successful
+// write counts against the limiter's daily cap.
+func (c *Client) write(ctx context.Context, kind, endpoint string, body, out interface{}) error {
+	if c.Limiter == nil {
+		return c.do(ctx, "POST", endpoint, body, out)
+	}
+	if err := c.Limiter.Wait(ctx, kind); err != nil {
+		return err
+	}
+	err := c.do(ctx, "POST", endpoint, body, out)
+	c.Limiter.Done(kind, err == nil)
+	return err
+}
+
+func refused(err error) bool {
+	return IsStatus(err, http.StatusTooManyRequests) || IsStatus(err, http.StatusServiceUnavailable)
+}
+
+func (c *Client) send(ctx context.Context, method, endpoint string, payload []byte, out interface{}) error {
+	status, start := 0, time.Now()
+	if c.Observe != nil {
+		defer func() { c.Observe(method, route(endpoint), status, time.Since(start)) }()
+	}
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("colosseum: %s %s: %w", method, endpoint, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("colosseum: %s %s: %w", method, endpoint, err)
+	}
+	defer resp.Body.Close()
+	status = resp.StatusCode
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("colosseum: %s %s: read response: %w", method, endpoint, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{
+			Method:     method,
+			Endpoint:   endpoint,
+			StatusCode: resp.StatusCode,
+			Body:       string(data),
+			retryAfter: retry.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		if method != "GET" {
+			// The write went through: reporting it as failed would get it
+			// sent again. Leave out empty, so the new ID reads as unknown.
+			v := reflect.ValueOf(out).Elem()
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return fmt.Errorf("colosseum: %s %s: decode response: %w", method, endpoint, err)
+	}
+	return nil
+}
+
+// ==================== Reads ====================
+
+func (c *Client) Status(ctx context.Context) (*AgentStatus, error) {
+	var s AgentStatus
+	if err := c.do(ctx, "GET", "/agents/status", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *Client) MyProject(ctx context.Context) (*Project, error) {
+	var p Project
+	if err := c.do(ctx, "GET", "/my-project", nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (c *Client) Posts(ctx context.Context, sort string, limit int) ([]Post, error) {
+	var r struct {
+		Posts []Post `json:"posts"`
+	}
+	err := c.do(ctx, "GET", fmt.Sprintf("/forum/posts?sort=%s&limit=%d", url.QueryEscape(sort), limit), nil, &r)
+	return r.Posts, err
+}
+
+// Post returns one post, sent either as {"post": {...}} or bare. A
+// response without a post ID is an error.
+func (c *Client) Post(ctx context.Context, postID int) (*Post, error) {
+	endpoint := fmt.Sprintf("/forum/posts/%d", postID)
+	var r struct {
+		Wrapped *Post `json:"post"`
+		Post
+	}
+	if err := c.do(ctx, "GET", endpoint, nil, &r); err != nil {
+		return nil, err
+	}
+	p := &r.Post
+	if r.Wrapped != nil {
+		p = r.Wrapped
+	}
+	if p.ID == 0 {
+		return nil, fmt.Errorf("colosseum: GET %s: no post in response", endpoint)
+	}
+	return p, nil
+}
+
+// Comments returns one page of a post's comments, newest first.
+func (c *Client) Comments(ctx context.Context, postID, limit, offset int) ([]Comment, error) {
+	var r struct {
+		Comments []Comment `json:"comments"`
+	}
+	err := c.do(ctx, "GET", fmt.Sprintf("/forum/posts/%d/comments?sort=new&limit=%d&offset=%d", postID, limit, offset), nil, &r)
+	return r.Comments, err
+}
+
+func (c *Client) ActiveHackathon(ctx context.Context) (*Hackathon, error) {
+	var h Hackathon
+	if err := c.do(ctx, "GET", "/hackathons/active", nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (c *Client) Leaderboard(ctx context.Context, hackathonID, limit int) ([]LeaderboardProject, error) {
+	var r struct {
+		Projects []LeaderboardProject `json:"projects"`
+	}
+	err := c.do(ctx, "GET", fmt.Sprintf("/hackathons/%d/leaderboard?limit=%d", hackathonID, limit), nil, &r)
+	return r.Projects, err
+}
+
+func (c *Client) Projects(ctx context.Context, includeDrafts bool) ([]ProjectInfo, error) {
+	endpoint := "/projects/current"
+	if includeDrafts {
+		endpoint = "/projects?includeDrafts=true"
+	}
+	var r struct {
+		Projects []ProjectInfo `json:"projects"`
+	}
+	err := c.do(ctx, "GET", endpoint, nil, &r)
+	return r.Projects, err
+}
+
+func (c *Client) SearchForum(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	var r struct {
+		Results []SearchResult `json:"results"`
+	}
+	err := c.do(ctx, "GET", fmt.Sprintf("/forum/search?q=%s&limit=%d", url.QueryEscape(query), limit), nil, &r)
+	return r.Results, err
+}
+
+// ==================== Writes ====================
+
+func (c *Client) VotePost(ctx context.Context, postID int) error {
+	return c.write(ctx, "vote", fmt.Sprintf("/forum/posts/%d/vote", postID), map[string]int{"value": 1}, nil)
+}
+
+// CreateComment posts body on a post, as an answer to comment parentID if
+// it isn't 0. It returns the new comment as the API echoes it back; its ID
+// is 0 if the response has none.
+func (c *Client) CreateComment(ctx context.Context, postID, parentID int, body string) (*Comment, error) {
+	req := map[string]interface{}{"body": body}
+	if parentID != 0 {
+		req["parentId"] = parentID
+	}
+	var r struct {
+		Wrapped *Comment `json:"comment"`
+		Comment
+	}
+	if err := c.write(ctx, "comment", fmt.Sprintf("/forum/posts/%d/comments", postID), req, &r); err != nil {
+		return nil, err
+	}
+	if r.Wrapped != nil {
+		return r.Wrapped, nil
+	}
+	return &r.Comment, nil
+}
+
+// CreatePost returns the new post as the API echoes it back, either as
+// {"post": {...}} or bare. Its ID is 0 if the response has none.
+func (c *Client) CreatePost(ctx context.Context, title, body string, tags []string) (*Post, error) {
+	var r struct {
+		Wrapped *Post `json:"post"`
+		Post
+	}
+	if err := c.write(ctx, "post", "/forum/posts", map[string]interface{}{"title": title, "body": body, "tags": tags}, &r); err != nil {
+		return nil, err
+	}
+	if r.Wrapped != nil {
+		return r.Wrapped, nil
+	}
+	return &r.Post, nil
+}
+
+func (c *Client) VoteProject(ctx context.Context, projectID int) error {
+	return c.write(ctx, "vote", fmt.Sprintf("/projects/%d/vote", projectID), nil, nil)
+}
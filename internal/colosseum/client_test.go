@@ -0,0 +1,284 @@
+package colosseum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nanopost/internal/retry"
+)
+
+var ctx = context.Background()
+
+// request is what the fake API saw.
+type request struct {
+	method, path, auth string
+	body               map[string]interface{}
+}
+
+// fakeAPI answers every request with status and reply, recording it.
+func fakeAPI(t *testing.T, status int, reply string) (*Client, *[]request) {
+	var seen []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.RequestURI(), auth: r.Header.Get("Authorization")}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			json.Unmarshal(data, &req.body)
+		}
+		seen = append(seen, req)
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL+"/api", "key-1", srv.Client()), &seen
+}
+
+func TestAPIError(t *testing.T) {
+	c, _ := fakeAPI(t, http.StatusTooManyRequests, strings.Repeat("slow down ", 50))
+	_, err := c.Status(ctx)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.Method != "GET" || apiErr.Endpoint != "/agents/status" || apiErr.StatusCode != 429 {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+	if !strings.HasPrefix(apiErr.Body, "slow down") || len(apiErr.Body) != 500 {
+		t.Errorf("Body should keep the whole response, got %d bytes", len(apiErr.Body))
+	}
+	if msg := err.Error(); !strings.Contains(msg, "HTTP 429") || !strings.HasSuffix(msg, "...") || len(msg) > 300 {
+		t.Errorf("Error() = %q, want the status and a truncated body", msg)
+	}
+	if !IsStatus(err, 429) || IsStatus(err, 500) || IsStatus(errors.New("x"), 429) {
+		t.Error("IsStatus mismatch")
+	}
+}
+
+func TestNon2xxIsAnError(t *testing.T) {
+	for _, status := range []int{301, 400, 404, 500} {
+		c, _ := fakeAPI(t, status, `{"posts":[{"id":1}]}`)
+		if posts, err := c.Posts(ctx, "new", 5); !IsStatus(err, status) || posts != nil {
+			t.Errorf("HTTP %d: posts = %v, err = %v", status, posts, err)
+		}
+	}
+}
+
+func TestReads(t *testing.T) {
+	c, seen := fakeAPI(t, 200, `{"posts":[{"id":7,"title":"Hi","agentName":"bob"}]}`)
+	posts, err := c.Posts(ctx, "hot & new", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].ID != 7 || posts[0].Title != "Hi" {
+		t.Errorf("posts = %+v", posts)
+	}
+	r := (*seen)[0]
+	if r.path != "/api/forum/posts?sort=hot+%26+new&limit=3" {
+		t.Errorf("path = %q, want the sort escaped", r.path)
+	}
+	if r.auth != "Bearer key-1" {
+		t.Errorf("Authorization = %q", r.auth)
+	}
+}
+
+func TestPostWrappedOrBare(t *testing.T) {
+	cases := []struct {
+		reply   string
+		wantID  int
+		wantErr bool
+	}{
+		{`{"post":{"id":9,"title":"Hi"}}`, 9, false},
+		{`{"id":9,"title":"Hi"}`, 9, false},
+		{`{"post":{}}`, 0, true},
+		{`{"error":"not here"}`, 0, true},
+	}
+	for _, tc := range cases {
+		c, _ := fakeAPI(t, 200, tc.reply)
+		p, err := c.Post(ctx, 9)
+		if tc.wantErr {
+			if err == nil || p != nil {
+				t.Errorf("%s: post = %+v, want an error", tc.reply, p)
+			}
+			continue
+		}
+		if err != nil || p.ID != tc.wantID || p.Title != "Hi" {
+			t.Errorf("%s: post = %+v, err = %v", tc.reply, p, err)
+		}
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	c, _ := fakeAPI(t, 200, `<html>maintenance</html>`)
+	_, err := c.Comments(ctx, 3, 50, 0)
+	if err == nil || !strings.Contains(err.Error(), "decode response") {
+		t.Errorf("err = %v, want a decode error", err)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		t.Error("a decode failure is not an APIError")
+	}
+}
+
+func TestWrites(t *testing.T) {
+	c, seen := fakeAPI(t, 201, `{"ok":true}`)
+	if _, err := c.CreateComment(ctx, 5, 0, "Nice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "T", "B", []string{"ai"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.VotePost(ctx, 6); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ method, path, field, value string }{
+		{"POST", "/api/forum/posts/5/comments", "body", "Nice"},
+		{"POST", "/api/forum/posts", "title", "T"},
+		{"POST", "/api/forum/posts/6/vote", "", ""},
+	}
+	for i, w := range want {
+		r := (*seen)[i]
+		if r.method != w.method || r.path != w.path {
+			t.Errorf("request %d = %s %s, want %s %s", i, r.method, r.path, w.method, w.path)
+		}
+		if w.field != "" && r.body[w.field] != w.value {
+			t.Errorf("request %d body = %v, want %s=%s", i, r.body, w.field, w.value)
+		}
+	}
+	if _, ok := (*seen)[0].body["parentId"]; ok {
+		t.Errorf("top-level comment sent a parentId: %v", (*seen)[0].body)
+	}
+	if (*seen)[2].body["value"] != 1.0 {
+		t.Errorf("vote body = %v, want value 1", (*seen)[2].body)
+	}
+}
+
+func TestCreatePostEcho(t *testing.T) {
+	for _, reply := range []string{`{"post":{"id":12,"title":"T"}}`, `{"id":12,"title":"T"}`} {
+		c, _ := fakeAPI(t, 201, reply)
+		p, err := c.CreatePost(ctx, "T", "B", nil)
+		if err != nil || p.ID != 12 {
+			t.Errorf("reply %s: post = %+v, err = %v", reply, p, err)
+		}
+	}
+	// No body at all: created, but the ID is unknown.
+	c, _ := fakeAPI(t, 204, "")
+	if p, err := c.CreatePost(ctx, "T", "B", nil); err != nil || p.ID != 0 {
+		t.Errorf("empty reply: post = %+v, err = %v", p, err)
+	}
+}
+
+// doneLimiter lets every write through and records what Done was told.
+type doneLimiter struct{ done []bool }
+
+func (*doneLimiter) Wait(context.Context, string) error { return nil }
+func (l *doneLimiter) Done(_ string, ok bool)           { l.done = append(l.done, ok) }
+
+func TestUndecodableWriteStillCounts(t *testing.T) {
+	c, seen := fakeAPI(t, 201, `<html>Created</html>`)
+	lim := &doneLimiter{}
+	c.Limiter = lim
+	p, err := c.CreatePost(ctx, "T", "B", nil)
+	if err != nil || p == nil || p.ID != 0 {
+		t.Fatalf("post = %+v, err = %v; want success with an unknown ID", p, err)
+	}
+	// A half-decoded echo must not pass for the new comment.
+	c, _ = fakeAPI(t, 201, `{"id":77,"parentId":"none"}`)
+	c.Limiter = lim
+	if cm, err := c.CreateComment(ctx, 5, 0, "Nice"); err != nil || cm.ID != 0 {
+		t.Errorf("comment = %+v, err = %v; want success with an unknown ID", cm, err)
+	}
+	if len(*seen) != 1 || len(lim.done) != 2 || !lim.done[0] || !lim.done[1] {
+		t.Errorf("requests = %d, Done = %v; want each write sent once and counted", len(*seen), lim.done)
+	}
+}
+
+func TestCreateCommentReply(t *testing.T) {
+	c, seen := fakeAPI(t, 201, `{"comment":{"id":77,"parentId":4}}`)
+	got, err := c.CreateComment(ctx, 5, 4, "Agreed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 77 || got.ParentID != 4 {
+		t.Errorf("comment = %+v, want the wrapped echo", got)
+	}
+	if (*seen)[0].body["parentId"] != 4.0 {
+		t.Errorf("body = %v, want parentId 4", (*seen)[0].body)
+	}
+}
+
+// flakyAPI fails with the given statuses in turn, then answers 200 "{}".
+func flakyAPI(t *testing.T, statuses ...int) (*Client, *int) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[calls-1])
+		}
+		io.WriteString(w, `{}`)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.URL, "k", srv.Client())
+	c.Read = retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	c.Write = retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	return c, &calls
+}
+
+func TestRetries(t *testing.T) {
+	cases := []struct {
+		name      string
+		statuses  []int
+		write     bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{"read retried on 502", []int{502, 503}, false, 3, false},
+		{"read not retried on 404", []int{404}, false, 1, true},
+		{"read gives up", []int{500, 500, 500}, false, 3, true},
+		{"write retried on 429", []int{429}, true, 2, false},
+		{"write retried on 503", []int{503}, true, 2, false},
+		// The write may have gone through behind the gateway: don't repeat it.
+		{"write not retried on 502", []int{502}, true, 1, true},
+		{"write not retried on 500", []int{500}, true, 1, true},
+	}
+	for _, tc := range cases {
+		c, calls := flakyAPI(t, tc.statuses...)
+		var err error
+		if tc.write {
+			err = c.VotePost(ctx, 1)
+		} else {
+			_, err = c.Status(ctx)
+		}
+		if *calls != tc.wantCalls || (err != nil) != tc.wantErr {
+			t.Errorf("%s: calls = %d, err = %v", tc.name, *calls, err)
+		}
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(429)
+	}))
+	defer srv.Close()
+	_, err := NewClient(srv.URL, "k", srv.Client()).Status(ctx)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter() != 7*time.Second || !apiErr.Retryable() {
+		t.Errorf("err = %#v, want a retryable APIError asking for 7s", err)
+	}
+}
+
+func TestCanceledContextStopsRetries(t *testing.T) {
+	c, calls := flakyAPI(t, 503, 503)
+	c.Read.BaseDelay = time.Hour
+	canceled, cancel := context.WithCancel(ctx)
+	c.Read.OnRetry = func(int, time.Duration, error) { cancel() }
+	if _, err := c.Status(canceled); !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Errorf("calls = %d, err = %v; want context.Canceled after the first try", *calls, err)
+	}
+}
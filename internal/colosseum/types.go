@@ -0,0 +1,57 @@
+package colosseum
+
+type AgentStatus struct {
+	Status    string `json:"status"`
+	Hackathon struct {
+		IsActive bool `json:"isActive"`
+	} `json:"hackathon"`
+	Engagement struct {
+		ForumPostCount     int    `json:"forumPostCount"`
+		RepliesOnYourPosts int    `json:"repliesOnYourPosts"`
+		ProjectStatus      string `json:"projectStatus"`
+	} `json:"engagement"`
+	NextSteps []string `json:"nextSteps"`
+}
+
+type Project struct {
+	Name         string `json:"name"`
+	AgentUpvotes int    `json:"agentUpvotes"`
+	HumanUpvotes int    `json:"humanUpvotes"`
+}
+
+type Post struct {
+	ID        int    `json:"id"`
+	AgentName string `json:"agentName"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+}
+
+type Comment struct {
+	ID        int    `json:"id"`
+	ParentID  int    `json:"parentId,omitempty"` // the comment this one answers; 0 = top level
+	AgentName string `json:"agentName"`
+	Body      string `json:"body"`
+}
+
+type Hackathon struct {
+	ID int `json:"id"`
+}
+
+type LeaderboardProject struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	AgentUpvotes int    `json:"agentUpvotes"`
+	HumanUpvotes int    `json:"humanUpvotes"`
+}
+
+type ProjectInfo struct {
+	ID             int    `json:"id"`
+	Slug           string `json:"slug"`
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	OwnerAgentName string `json:"ownerAgentName"`
+}
+
+type SearchResult struct {
+	AgentName string `json:"agentName"`
+}
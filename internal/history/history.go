@@ -0,0 +1,347 @@
+// Package history is the optional SQLite state backend: it remembers what
+// the bot has already handled and keeps a searchable record of every
+// interaction and round. It uses a pure-Go driver, so no cgo is needed.
+package history
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS seen (
+	kind TEXT NOT NULL,
+	id   INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	time TIMESTAMP NOT NULL,
+	PRIMARY KEY (kind, id, name)
+);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS interactions (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	time      TIMESTAMP NOT NULL,
+	kind      TEXT NOT NULL,
+	post_id   INTEGER NOT NULL DEFAULT 0,
+	target_id INTEGER NOT NULL DEFAULT 0,
+	comment_id INTEGER NOT NULL DEFAULT 0,
+	agent     TEXT NOT NULL DEFAULT '',
+	received  TEXT NOT NULL DEFAULT '',
+	sent      TEXT NOT NULL DEFAULT '',
+	detail    TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS interactions_time ON interactions (time);
+CREATE INDEX IF NOT EXISTS interactions_agent ON interactions (agent);
+CREATE TABLE IF NOT EXISTS rounds (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	time             TIMESTAMP NOT NULL,
+	replies          INTEGER NOT NULL,
+	votes            INTEGER NOT NULL,
+	project_votes    INTEGER NOT NULL,
+	engagements      INTEGER NOT NULL,
+	mentions         INTEGER NOT NULL,
+	leaderboard_rank INTEGER NOT NULL,
+	stats            TEXT NOT NULL
+);`
+
+// Entry is one interaction. Which fields are set depends on Kind:
+//
+//	reply        PostID, TargetID (their comment), CommentID (our reply), Agent, Received, Sent
+//	comment      PostID (their post), CommentID, Agent, Received (post title), Sent
+//	vote         PostID, Agent
+//	project_vote TargetID (project), Agent (owner)
+//	post         PostID when known, Sent (title and body)
+//	tweet        Sent, Detail (tweet type)
+//
+// Detail holds the rule that triggered the action.
+type Entry struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	PostID    int       `json:"post_id,omitempty"`
+	TargetID  int       `json:"target_id,omitempty"`
+	CommentID int       `json:"comment_id,omitempty"` // our comment, when the API returned its ID
+	Agent     string    `json:"agent,omitempty"`
+	Received  string    `json:"received,omitempty"`
+	Sent      string    `json:"sent,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Round is the summary of one heartbeat or single-action command.
+type Round struct {
+	ID              int64     `json:"id"`
+	Time            time.Time `json:"time"`
+	Replies         int       `json:"replies"`
+	Votes           int       `json:"votes"`
+	ProjectVotes    int       `json:"project_votes"`
+	Engagements     int       `json:"engagements"`
+	Mentions        int       `json:"mentions"`
+	LeaderboardRank int       `json:"leaderboard_rank,omitempty"`
+	Stats           string    `json:"stats"` // the full round stats as JSON
+}
+
+type DB struct {
+	db   *sql.DB
+	path string
+}
+
+// Open opens (creating if needed) the database at path.
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("history: open %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1) // one writer; keeps the pragmas on a single connection
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history: %s: create schema: %w", path, err)
+	}
+	if err := addColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history: %s: upgrade schema: %w", path, err)
+	}
+	return &DB{db: db, path: path}, nil
+}
+
+// addedColumns are the columns added to interactions after its first
+// release; databases created before get them on open.
+var addedColumns = []struct{ name, def string }{
+	{"comment_id", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+func addColumns(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('interactions')`)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range addedColumns {
+		if !have[c.name] {
+			if _, err := db.Exec(`ALTER TABLE interactions ADD COLUMN ` + c.name + ` ` + c.def); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DB) Close() error { return d.db.Close() }
+
+func (d *DB) Path() string { return d.path }
+
+// Empty reports whether nothing has been marked as seen yet, i.e. the
+// database was just created.
+func (d *DB) Empty() (bool, error) {
+	var n int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM seen`).Scan(&n); err != nil {
+		return false, fmt.Errorf("history: %w", err)
+	}
+	return n == 0, nil
+}
+
+// Seen reports whether kind/id/name was marked before. Numeric kinds
+// (comment, post, project, own_post, declined) use id; named kinds (agent) use name.
+func (d *DB) Seen(kind string, id int, name string) (bool, error) {
+	var one int
+	err := d.db.QueryRow(`SELECT 1 FROM seen WHERE kind = ? AND id = ? AND name = ?`, kind, id, name).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("history: %w", err)
+	}
+	return true, nil
+}
+
+// MarkSeen records kind/id/name. Marking it again keeps the first time.
+func (d *DB) MarkSeen(kind string, id int, name string, t time.Time) error {
+	_, err := d.db.Exec(`INSERT OR IGNORE INTO seen (kind, id, name, time) VALUES (?, ?, ?, ?)`, kind, id, name, t.UTC())
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// IDs returns the ids marked for a numeric kind, highest first.
+func (d *DB) IDs(kind string) ([]int, error) {
+	rows, err := d.db.Query(`SELECT id FROM seen WHERE kind = ? ORDER BY id DESC`, kind)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Counts returns how many entries of each seen kind are stored.
+func (d *DB) Counts() (map[string]int, error) {
+	rows, err := d.db.Query(`SELECT kind, COUNT(*) FROM seen GROUP BY kind`)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var kind string
+		var n int
+		if err := rows.Scan(&kind, &n); err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		counts[kind] = n
+	}
+	return counts, rows.Err()
+}
+
+// Get returns a value stored with Set, and whether it exists.
+func (d *DB) Get(key string) (string, bool, error) {
+	var value string
+	err := d.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("history: %w", err)
+	}
+	return value, true, nil
+}
+
+// Set stores a small named value, e.g. the time of the last progress post.
+func (d *DB) Set(key, value string) error {
+	_, err := d.db.Exec(`INSERT INTO meta (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// Add stores an interaction. A zero Time means now.
+func (d *DB) Add(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	_, err := d.db.Exec(`INSERT INTO interactions (time, kind, post_id, target_id, comment_id, agent, received, sent, detail) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Time.UTC(), e.Kind, e.PostID, e.TargetID, e.CommentID, e.Agent, e.Received, e.Sent, e.Detail)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// AddRound stores the stats of one round. A zero Time means now.
+func (d *DB) AddRound(r Round) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	_, err := d.db.Exec(`INSERT INTO rounds (time, replies, votes, project_votes, engagements, mentions, leaderboard_rank, stats) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Time.UTC(), r.Replies, r.Votes, r.ProjectVotes, r.Engagements, r.Mentions, r.LeaderboardRank, r.Stats)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// Query selects interactions. Empty fields match everything.
+type Query struct {
+	Kind  string    // exact kind
+	Agent string    // exact agent name, without @
+	Text  string    // substring of what we received, sent or the rule
+	Since time.Time // only entries at or after this time
+	Limit int       // newest first; 0 = 50
+}
+
+// Search returns the newest interactions matching q.
+func (d *DB) Search(q Query) ([]Entry, error) {
+	var where []string
+	var args []interface{}
+	if q.Kind != "" {
+		where = append(where, "kind = ?")
+		args = append(args, q.Kind)
+	}
+	if q.Agent != "" {
+		where = append(where, "agent = ? COLLATE NOCASE")
+		args = append(args, q.Agent)
+	}
+	if q.Text != "" {
+		where = append(where, "(received LIKE ? OR sent LIKE ? OR detail LIKE ?)")
+		pattern := "%" + q.Text + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	query := `SELECT id, time, kind, post_id, target_id, comment_id, agent, received, sent, detail FROM interactions`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += " ORDER BY time DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Time, &e.Kind, &e.PostID, &e.TargetID, &e.CommentID, &e.Agent, &e.Received, &e.Sent, &e.Detail); err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		e.Time = e.Time.Local()
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Rounds returns the newest rounds; limit 0 = 20.
+func (d *DB) Rounds(limit int) ([]Round, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := d.db.Query(`SELECT id, time, replies, votes, project_votes, engagements, mentions, leaderboard_rank, stats FROM rounds ORDER BY time DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer rows.Close()
+	var rounds []Round
+	for rows.Next() {
+		var r Round
+		if err := rows.Scan(&r.ID, &r.Time, &r.Replies, &r.Votes, &r.ProjectVotes, &r.Engagements, &r.Mentions, &r.LeaderboardRank, &r.Stats); err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		r.Time = r.Time.Local()
+		rounds = append(rounds, r)
+	}
+	return rounds, rows.Err()
+}
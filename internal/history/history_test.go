@@ -0,0 +1,106 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTemp(t *testing.T) *DB {
+	db, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSeen(t *testing.T) {
+	db := openTemp(t)
+	if empty, err := db.Empty(); err != nil || !empty {
+		t.Fatalf("new database: Empty = %v, %v", empty, err)
+	}
+	now := time.Now()
+	db.MarkSeen("comment", 1, "", now)
+	db.MarkSeen("comment", 1, "", now) // again: ignored
+	db.MarkSeen("agent", 0, "alice", now)
+
+	for _, c := range []struct {
+		kind string
+		id   int
+		name string
+		want bool
+	}{
+		{"comment", 1, "", true},
+		{"post", 1, "", false}, // same id, other kind
+		{"agent", 0, "alice", true},
+		{"agent", 0, "bob", false},
+	} {
+		if got, err := db.Seen(c.kind, c.id, c.name); err != nil || got != c.want {
+			t.Errorf("Seen(%s, %d, %q) = %v, %v; want %v", c.kind, c.id, c.name, got, err, c.want)
+		}
+	}
+	counts, err := db.Counts()
+	if err != nil || counts["comment"] != 1 || counts["agent"] != 1 {
+		t.Errorf("Counts = %v, %v", counts, err)
+	}
+}
+
+func TestMeta(t *testing.T) {
+	db := openTemp(t)
+	if _, ok, err := db.Get("topic_index"); ok || err != nil {
+		t.Fatalf("Get on a new database = %v, %v", ok, err)
+	}
+	db.Set("topic_index", "2")
+	db.Set("topic_index", "3")
+	if v, ok, err := db.Get("topic_index"); v != "3" || !ok || err != nil {
+		t.Errorf("Get = %q, %v, %v; want the last value", v, ok, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db := openTemp(t)
+	base := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: base, Kind: "reply", Agent: "Alice", Received: "what is identity?", Sent: "a story"},
+		{Time: base.Add(time.Hour), Kind: "vote", Agent: "bob", PostID: 4},
+		{Time: base.Add(2 * time.Hour), Kind: "reply", Agent: "bob", Sent: "thanks", Detail: "mention"},
+	}
+	for _, e := range entries {
+		if err := db.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name  string
+		q     Query
+		kinds []string // expected, newest first
+	}{
+		{"all, newest first", Query{}, []string{"reply", "vote", "reply"}},
+		{"kind", Query{Kind: "vote"}, []string{"vote"}},
+		{"agent ignores case", Query{Agent: "alice"}, []string{"reply"}},
+		{"text matches the rule", Query{Text: "mention"}, []string{"reply"}},
+		{"since", Query{Since: base.Add(time.Hour)}, []string{"reply", "vote"}},
+		{"limit", Query{Limit: 1}, []string{"reply"}},
+	}
+	for _, tt := range tests {
+		got, err := db.Search(tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kinds []string
+		for _, e := range got {
+			kinds = append(kinds, e.Kind)
+		}
+		if len(kinds) != len(tt.kinds) {
+			t.Errorf("%s: got %v, want %v", tt.name, kinds, tt.kinds)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != tt.kinds[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, kinds, tt.kinds)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package logfile is an append-only log file that rotates by size or at
+// local midnight and deletes old rotated files.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy says when to rotate and how many rotated files to keep.
+type Policy struct {
+	Mode     string        // "" = never rotate, "size" or "daily"
+	MaxSize  int64         // size mode: rotate before a write would pass this many bytes
+	MaxFiles int           // rotated files kept, newest first; 0 = all
+	MaxAge   time.Duration // rotated files older than this are deleted; 0 = never
+}
+
+// File is safe for concurrent use. Rotated files sit next to the live one:
+// nanopost_log.txt becomes nanopost_log-2024-05-01.txt (daily) or
+// nanopost_log-2024-05-01T15-04-05.txt (size).
+type File struct {
+	mu     sync.Mutex
+	path   string
+	policy Policy
+	f      *os.File
+	size   int64
+	day    string // local date of the content in f, for daily rotation
+}
+
+func Open(path string, p Policy) (*File, error) {
+	lf := &File{path: path, policy: p}
+	if err := lf.open(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+func (lf *File) open() error {
+	f, err := os.OpenFile(lf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	lf.f, lf.size = f, info.Size()
+	lf.day = time.Now().Format("2006-01-02")
+	if info.Size() > 0 {
+		lf.day = info.ModTime().Format("2006-01-02")
+	}
+	return nil
+}
+
+// SetPolicy changes the rotation policy, e.g. after a config reload.
+func (lf *File) SetPolicy(p Policy) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	lf.policy = p
+}
+
+// Write appends p, rotating first if the policy says so. A failed rotation
+// is returned as the error, but the data still goes to the current file.
+func (lf *File) Write(p []byte) (int, error) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.f == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if lf.due(len(p), time.Now()) {
+		rotateErr = lf.rotate()
+		if lf.f == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err := lf.f.Write(p)
+	lf.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+func (lf *File) due(n int, now time.Time) bool {
+	switch lf.policy.Mode {
+	case "size":
+		return lf.policy.MaxSize > 0 && lf.size > 0 && lf.size+int64(n) > lf.policy.MaxSize
+	case "daily":
+		return lf.size > 0 && now.Format("2006-01-02") != lf.day
+	}
+	return false
+}
+
+// rotate renames the live file aside, reopens it and prunes old files.
+// The caller holds lf.mu.
+func (lf *File) rotate() error {
+	stamp := lf.day
+	if lf.policy.Mode == "size" {
+		stamp = time.Now().Format("2006-01-02T15-04-05")
+	}
+	base, ext := lf.split()
+	name := base + "-" + stamp + ext
+	for i := 1; exists(name); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", base, stamp, i, ext)
+	}
+	lf.f.Close()
+	renameErr := os.Rename(lf.path, name)
+	if err := lf.open(); err != nil {
+		lf.f = nil
+		return fmt.Errorf("logfile: reopen %s: %w", lf.path, err)
+	}
+	if renameErr != nil {
+		return fmt.Errorf("logfile: rotate %s: %w", lf.path, renameErr)
+	}
+	return lf.prune()
+}
+
+func (lf *File) split() (base, ext string) {
+	ext = filepath.Ext(lf.path)
+	return strings.TrimSuffix(lf.path, ext), ext
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Rotated lists the rotated files, oldest first.
+func (lf *File) Rotated() ([]string, error) {
+	base, ext := lf.split()
+	dir, prefix := filepath.Dir(base), filepath.Base(base)+"-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type rotated struct {
+		path string
+		mod  time.Time
+	}
+	var found []rotated
+	for _, e := range entries {
+		name := e.Name()
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if !e.Type().IsRegular() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) || !isStamp(stamp) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		found = append(found, rotated{filepath.Join(dir, name), info.ModTime()})
+	}
+	// A rotated file is last written just before it is renamed aside.
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].mod.Equal(found[j].mod) {
+			return found[i].mod.Before(found[j].mod)
+		}
+		return found[i].path < found[j].path
+	})
+	files := make([]string, len(found))
+	for i, r := range found {
+		files[i] = r.path
+	}
+	return files, nil
+}
+
+// isStamp matches the dates and times rotate puts in file names.
+func isStamp(s string) bool {
+	if len(s) < 10 {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", s[:10])
+	return err == nil
+}
+
+func (lf *File) prune() error {
+	files, err := lf.Rotated()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	remove := func(path string) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if n := lf.policy.MaxFiles; n > 0 && len(files) > n {
+		for _, path := range files[:len(files)-n] {
+			remove(path)
+		}
+		files = files[len(files)-n:]
+	}
+	if lf.policy.MaxAge > 0 {
+		cutoff := time.Now().Add(-lf.policy.MaxAge)
+		for _, path := range files {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				remove(path)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("logfile: prune: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (lf *File) Sync() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.f == nil {
+		return nil
+	}
+	return lf.f.Sync()
+}
+
+func (lf *File) Close() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.f == nil {
+		return nil
+	}
+	err := lf.f.Close()
+	lf.f = nil
+	return err
+}
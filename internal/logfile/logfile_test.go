@@ -0,0 +1,126 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func read(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSizeRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	lf, err := Open(path, Policy{Mode: "size", MaxSize: 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+	for _, line := range []string{"aaaaaa\n", "bbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := lf.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// aaaaaa+bbb fit in 11 bytes; each of the next lines starts a new file.
+	if got := read(t, path); got != "dddddd\n" {
+		t.Errorf("live file = %q", got)
+	}
+	rotated, err := lf.Rotated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("rotated = %v, want 2 files", rotated)
+	}
+	// Same-second rotations get a counter instead of overwriting each other.
+	contents := read(t, rotated[0]) + read(t, rotated[1])
+	if !strings.Contains(contents, "aaaaaa\nbbb\n") || !strings.Contains(contents, "cccccc\n") {
+		t.Errorf("rotated contents = %q", contents)
+	}
+}
+
+func TestOversizedWriteStillLands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	lf, _ := Open(path, Policy{Mode: "size", MaxSize: 4})
+	defer lf.Close()
+	lf.Write([]byte("a line longer than the limit\n"))
+	if got := read(t, path); got != "a line longer than the limit\n" {
+		t.Errorf("an empty file must take a write of any size, got %q", got)
+	}
+}
+
+func TestDailyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	os.WriteFile(path, []byte("yesterday\n"), 0644)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	os.Chtimes(path, yesterday, yesterday)
+
+	lf, err := Open(path, Policy{Mode: "daily"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+	lf.Write([]byte("today\n"))
+	lf.Write([]byte("still today\n"))
+
+	want := filepath.Join(filepath.Dir(path), "bot-"+yesterday.Format("2006-01-02")+".log")
+	if got := read(t, want); got != "yesterday\n" {
+		t.Errorf("%s = %q", want, got)
+	}
+	if got := read(t, path); got != "today\nstill today\n" {
+		t.Errorf("live file = %q", got)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bot.log")
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	for i, name := range []string{"bot-2026-01-01.log", "bot-2026-01-02.log", "bot-2026-01-03.log"} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte("x"), 0644)
+		at := old.Add(time.Duration(i) * time.Hour)
+		os.Chtimes(p, at, at)
+	}
+	// Not ours: no date stamp, or another log's prefix.
+	os.WriteFile(filepath.Join(dir, "bot-notes.log"), []byte("keep"), 0644)
+	os.WriteFile(filepath.Join(dir, "other-2026-01-01.log"), []byte("keep"), 0644)
+
+	lf, _ := Open(path, Policy{Mode: "size", MaxSize: 1, MaxFiles: 2})
+	defer lf.Close()
+	lf.Write([]byte("1"))
+	lf.Write([]byte("2")) // rotates "1" aside and prunes to the newest two
+	rotated, _ := lf.Rotated()
+	if len(rotated) != 2 || filepath.Base(rotated[0]) != "bot-2026-01-03.log" {
+		t.Errorf("after MaxFiles: %v, want 01-03 and the new file", rotated)
+	}
+
+	lf.SetPolicy(Policy{Mode: "size", MaxSize: 1, MaxAge: 24 * time.Hour})
+	lf.Write([]byte("3"))
+	rotated, _ = lf.Rotated()
+	for _, p := range rotated {
+		if strings.Contains(p, "2026-01-03") {
+			t.Errorf("after MaxAge: %v still has the 10 day old file", rotated)
+		}
+	}
+	for _, name := range []string{"bot-notes.log", "other-2026-01-01.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was pruned: %v", name, err)
+		}
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	lf, _ := Open(filepath.Join(t.TempDir(), "bot.log"), Policy{})
+	lf.Close()
+	if _, err := lf.Write([]byte("x")); err != os.ErrClosed {
+		t.Errorf("Write after Close = %v, want os.ErrClosed", err)
+	}
+}
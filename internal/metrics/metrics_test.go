@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(r *Registry) string {
+	var b strings.Builder
+	r.WriteTo(&b)
+	return b.String()
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []float64
+		observe []float64
+		want    string
+	}{
+		{
+			name:    "cumulative buckets",
+			buckets: []float64{0.1, 1, 10},
+			observe: []float64{0.05, 0.5, 0.7, 5, 50},
+			want: `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="10"} 4
+latency_seconds_bucket{le="+Inf"} 5
+latency_seconds_sum 56.25
+latency_seconds_count 5
+`,
+		},
+		{
+			name:    "upper bound is inclusive",
+			buckets: []float64{1, 2},
+			observe: []float64{1, 2},
+			want: `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="1"} 1
+latency_seconds_bucket{le="2"} 2
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 3
+latency_seconds_count 2
+`,
+		},
+		{
+			name:    "unsorted buckets are sorted",
+			buckets: []float64{5, 0.5},
+			observe: []float64{1},
+			want: `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.5"} 0
+latency_seconds_bucket{le="5"} 1
+latency_seconds_bucket{le="+Inf"} 1
+latency_seconds_sum 1
+latency_seconds_count 1
+`,
+		},
+		{
+			name:    "no observations",
+			buckets: []float64{1},
+			want: `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			h := r.Histogram("latency_seconds", "Latency.", tt.buckets)
+			for _, v := range tt.observe {
+				h.Observe(v)
+			}
+			if got := render(r); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelledHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("api_seconds", "API latency.", []float64{1}, "agent", "endpoint")
+	h.Observe(0.5, "bob", "posts")
+	h.Observe(2, "alice", "posts")
+	want := `# HELP api_seconds API latency.
+# TYPE api_seconds histogram
+api_seconds_bucket{agent="alice",endpoint="posts",le="1"} 0
+api_seconds_bucket{agent="alice",endpoint="posts",le="+Inf"} 1
+api_seconds_sum{agent="alice",endpoint="posts"} 2
+api_seconds_count{agent="alice",endpoint="posts"} 1
+api_seconds_bucket{agent="bob",endpoint="posts",le="1"} 1
+api_seconds_bucket{agent="bob",endpoint="posts",le="+Inf"} 1
+api_seconds_sum{agent="bob",endpoint="posts"} 0.5
+api_seconds_count{agent="bob",endpoint="posts"} 1
+`
+	if got := render(r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	tests := []struct {
+		name, help, label string
+		want              string
+	}{
+		{
+			name:  "plain",
+			help:  "Actions taken.",
+			label: "reply",
+			want:  "# HELP actions_total Actions taken.\n# TYPE actions_total counter\nactions_total{action=\"reply\"} 1\n",
+		},
+		{
+			name:  "quotes and backslashes in labels",
+			help:  "Actions taken.",
+			label: `say "hi" \o/`,
+			want:  "# HELP actions_total Actions taken.\n# TYPE actions_total counter\nactions_total{action=\"say \\\"hi\\\" \\\\o/\"} 1\n",
+		},
+		{
+			name:  "newlines",
+			help:  "Two\nlines \\ here.",
+			label: "a\nb",
+			want:  "# HELP actions_total Two\\nlines \\\\ here.\n# TYPE actions_total counter\nactions_total{action=\"a\\nb\"} 1\n",
+		},
+		{
+			name:  "quotes in help are kept",
+			help:  `The "real" count.`,
+			label: "vote",
+			want:  "# HELP actions_total The \"real\" count.\n# TYPE actions_total counter\nactions_total{action=\"vote\"} 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Counter("actions_total", tt.help, "action").Inc(tt.label)
+			if got := render(r); got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("posts_total", "Posts.")
+	c.Inc()
+	c.Add(2.5)
+	c.Add(-10) // ignored
+	g := r.Gauge("rank", "Leaderboard rank.", "agent")
+	g.Set(7, "bob")
+	g.Set(3, "bob")
+	g.Set(1e-7, "alice")
+	want := `# HELP posts_total Posts.
+# TYPE posts_total counter
+posts_total 3.5
+# HELP rank Leaderboard rank.
+# TYPE rank gauge
+rank{agent="alice"} 1e-07
+rank{agent="bob"} 3
+`
+	if got := render(r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("x_total", "X.", "agent")
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc without the agent label did not panic")
+		}
+	}()
+	c.Inc()
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("up_total", "Up.").Inc()
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "up_total 1\n") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,198 @@
+// Package ratelimit provides per-action token buckets and rolling 24h caps
+// whose counts survive restarts.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"nanopost/internal/atomicfile"
+)
+
+// ErrDailyCap is returned (wrapped) when an action kind has used up its
+// allowance for the last 24 hours.
+var ErrDailyCap = errors.New("daily cap reached")
+
+// Rule configures one action kind.
+type Rule struct {
+	Interval time.Duration // one token per interval; 0 = no pacing
+	Burst    int           // bucket size; < 1 is treated as 1
+	DailyCap int           // max actions per rolling 24h; 0 = unlimited
+}
+
+type bucket struct {
+	rule   Rule
+	tokens float64
+	last   time.Time
+}
+
+// Limiter paces actions per kind ("vote", "comment", "post", "llm", ...).
+// Kinds without a rule are never limited. An action counts against the
+// daily cap only once Done reports that it succeeded, and Done saves the
+// counts right away so a crash can't reset them.
+type Limiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	history  map[string][]time.Time // action times within the last 24h
+	pending  map[string]int         // actions between Wait and Done
+	path     string
+	dirty    bool // history changed since it was last saved
+	simulate bool
+}
+
+// New builds a limiter and loads the persisted 24h history from path
+// (empty path = in memory only). A missing file is not an error.
+func New(rules map[string]Rule, path string) (*Limiter, error) {
+	l := &Limiter{buckets: map[string]*bucket{}, history: map[string][]time.Time{}, pending: map[string]int{}, path: path}
+	for kind, r := range rules {
+		if r.Burst < 1 {
+			r.Burst = 1
+		}
+		l.buckets[kind] = &bucket{rule: r, tokens: float64(r.Burst)}
+	}
+	if path == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.history); err != nil {
+		return nil, fmt.Errorf("ratelimit: parse %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Simulate keeps pacing and cap checks but stops counting actions, for
+// dry runs.
+func (l *Limiter) Simulate() {
+	l.mu.Lock()
+	l.simulate = true
+	l.mu.Unlock()
+}
+
+// Wait blocks until kind may run and holds one of its daily slots until
+// Done. It fails fast with ErrDailyCap instead of waiting for the window to
+// roll, and returns ctx.Err() if ctx is done before the token is available;
+// on error no slot is held.
+func (l *Limiter) Wait(ctx context.Context, kind string) error {
+	l.mu.Lock()
+	b, ok := l.buckets[kind]
+	if !ok {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	l.prune(kind, now)
+	if b.rule.DailyCap > 0 && len(l.history[kind])+l.pending[kind] >= b.rule.DailyCap {
+		l.mu.Unlock()
+		return fmt.Errorf("ratelimit: %s: %w (%d per 24h)", kind, ErrDailyCap, b.rule.DailyCap)
+	}
+	wait := b.reserve(now)
+	l.pending[kind]++
+	l.mu.Unlock()
+
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			l.mu.Lock()
+			b.tokens++
+			l.pending[kind]--
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Done releases the slot taken by a successful Wait and, if ok, counts the
+// action against the daily cap and saves the counts. A failed save leaves
+// them for the next Flush to retry and report.
+func (l *Limiter) Done(kind string, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, limited := l.buckets[kind]; !limited {
+		return
+	}
+	l.pending[kind]--
+	if ok && !l.simulate {
+		l.history[kind] = append(l.history[kind], time.Now())
+		l.dirty = true
+		l.save()
+	}
+}
+
+// Used returns how many kind actions ran in the last 24h and the cap.
+func (l *Limiter) Used(kind string) (used, dailyCap int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(kind, time.Now())
+	if b, ok := l.buckets[kind]; ok {
+		dailyCap = b.rule.DailyCap
+	}
+	return len(l.history[kind]), dailyCap
+}
+
+// reserve takes a token, letting the balance go negative, and returns how
+// long the caller has to wait for it.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if b.rule.Interval <= 0 {
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens += float64(now.Sub(b.last)) / float64(b.rule.Interval)
+		if burst := float64(b.rule.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.rule.Interval))
+}
+
+func (l *Limiter) prune(kind string, now time.Time) {
+	h := l.history[kind]
+	cutoff := now.Add(-24 * time.Hour)
+	i := 0
+	for i < len(h) && h[i].Before(cutoff) {
+		i++
+	}
+	l.history[kind] = h[i:]
+}
+
+// Flush saves the 24h history if a save in Done failed since.
+func (l *Limiter) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.save()
+}
+
+// save writes the history if it is dirty. l.mu must be held.
+func (l *Limiter) save() error {
+	if !l.dirty || l.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(l.history)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(l.path, data, 0644); err != nil {
+		return fmt.Errorf("ratelimit: save %s: %w", l.path, err)
+	}
+	l.dirty = false
+	return nil
+}
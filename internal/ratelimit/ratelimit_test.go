@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	type call struct {
+		at   time.Duration // since start
+		want time.Duration
+	}
+	tests := []struct {
+		name  string
+		rule  Rule
+		calls []call
+	}{
+		{
+			name:  "no pacing",
+			rule:  Rule{Burst: 1},
+			calls: []call{{0, 0}, {0, 0}, {0, 0}},
+		},
+		{
+			name:  "one per interval",
+			rule:  Rule{Interval: 10 * time.Second, Burst: 1},
+			calls: []call{{0, 0}, {0, 10 * time.Second}, {0, 20 * time.Second}},
+		},
+		{
+			name:  "burst then pacing",
+			rule:  Rule{Interval: 10 * time.Second, Burst: 3},
+			calls: []call{{0, 0}, {0, 0}, {0, 0}, {0, 10 * time.Second}},
+		},
+		{
+			name:  "refills with time",
+			rule:  Rule{Interval: 10 * time.Second, Burst: 1},
+			calls: []call{{0, 0}, {4 * time.Second, 6 * time.Second}, {30 * time.Second, 0}},
+		},
+		{
+			name:  "refill is capped at burst",
+			rule:  Rule{Interval: time.Second, Burst: 2},
+			calls: []call{{0, 0}, {time.Hour, 0}, {time.Hour, 0}, {time.Hour, time.Second}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bucket{rule: tt.rule, tokens: float64(tt.rule.Burst)}
+			for i, c := range tt.calls {
+				if got := b.reserve(start.Add(c.at)); got != c.want {
+					t.Fatalf("call %d at +%v: wait %v, want %v", i, c.at, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+// act runs one kind action through l and reports ok to Done.
+func act(t *testing.T, l *Limiter, kind string, ok bool) {
+	t.Helper()
+	if err := l.Wait(context.Background(), kind); err != nil {
+		t.Fatalf("Wait(%s) = %v", kind, err)
+	}
+	l.Done(kind, ok)
+}
+
+func TestDailyCap(t *testing.T) {
+	l, err := New(map[string]Rule{"vote": {DailyCap: 2}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	act(t, l, "vote", false) // failures don't count
+	act(t, l, "vote", true)
+	act(t, l, "vote", true)
+	if err := l.Wait(context.Background(), "vote"); !errors.Is(err, ErrDailyCap) {
+		t.Errorf("Wait after two successes = %v, want ErrDailyCap", err)
+	}
+	if used, limit := l.Used("vote"); used != 2 || limit != 2 {
+		t.Errorf("Used = %d/%d, want 2/2", used, limit)
+	}
+	// Kinds without a rule are neither limited nor counted.
+	for i := 0; i < 5; i++ {
+		act(t, l, "llm", true)
+	}
+	if used, limit := l.Used("llm"); used != 0 || limit != 0 {
+		t.Errorf("Used(llm) = %d/%d, want 0/0", used, limit)
+	}
+}
+
+func TestSimulateDoesNotCount(t *testing.T) {
+	l, _ := New(map[string]Rule{"post": {DailyCap: 1}}, "")
+	l.Simulate()
+	act(t, l, "post", true)
+	act(t, l, "post", true)
+	if used, _ := l.Used("post"); used != 0 {
+		t.Errorf("Used = %d in a dry run, want 0", used)
+	}
+}
+
+func TestPendingHoldsASlot(t *testing.T) {
+	l, _ := New(map[string]Rule{"post": {DailyCap: 1}}, "")
+	ctx := context.Background()
+	if err := l.Wait(ctx, "post"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Wait(ctx, "post"); !errors.Is(err, ErrDailyCap) {
+		t.Fatalf("second Wait before Done = %v, want ErrDailyCap", err)
+	}
+	l.Done("post", false)
+	if err := l.Wait(ctx, "post"); err != nil {
+		t.Fatalf("Wait after a failed action = %v, want nil", err)
+	}
+}
+
+func TestWaitCanceledRefunds(t *testing.T) {
+	l, _ := New(map[string]Rule{"comment": {Interval: time.Hour, DailyCap: 2}}, "")
+	act(t, l, "comment", true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := l.Wait(ctx, "comment"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v, want context.Canceled", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Wait took %v after cancel, want it to return at once", time.Since(start))
+	}
+	if got := l.pending["comment"]; got != 0 {
+		t.Errorf("pending = %d after cancel, want 0", got)
+	}
+	if got := l.buckets["comment"].tokens; got < 0 || got > 0.01 {
+		t.Errorf("tokens = %v after cancel, want about 0 (refunded)", got)
+	}
+}
+
+func TestDoneSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	rules := map[string]Rule{"comment": {DailyCap: 3}}
+	l, err := New(rules, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act(t, l, "comment", false)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("a failed action wrote the file (stat: %v)", err)
+	}
+	act(t, l, "comment", true)
+	act(t, l, "comment", true)
+
+	// No Flush: as if the process died right after the second comment.
+	reloaded, err := New(rules, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := reloaded.Used("comment"); used != 2 {
+		t.Errorf("Used after reload = %d, want 2", used)
+	}
+	act(t, reloaded, "comment", true)
+	if err := reloaded.Wait(context.Background(), "comment"); !errors.Is(err, ErrDailyCap) {
+		t.Errorf("Wait past the persisted cap = %v, want ErrDailyCap", err)
+	}
+}
+
+func TestFlushRetriesAFailedSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "later")
+	path := filepath.Join(dir, "ratelimit.json")
+	l, err := New(map[string]Rule{"post": {}}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act(t, l, "post", true) // the directory doesn't exist yet: the save fails
+	if err := l.Flush(); err == nil {
+		t.Fatal("Flush hid the failed save")
+	}
+	os.Mkdir(dir, 0755)
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"post"`) {
+		t.Errorf("saved %q, want the post counted", data)
+	}
+}
+
+func TestLoadPrunesOldActions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	old := time.Now().Add(-25 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	if err := os.WriteFile(path, []byte(`{"vote":["`+old+`","`+recent+`"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(map[string]Rule{"vote": {DailyCap: 5}}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := l.Used("vote"); used != 1 {
+		t.Errorf("Used = %d, want 1 (the 25h old action dropped)", used)
+	}
+}
+
+func TestLoadRejectsGarbage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	os.WriteFile(path, []byte("not json"), 0644)
+	if _, err := New(nil, path); err == nil {
+		t.Error("New accepted a corrupt history file")
+	}
+}
@@ -0,0 +1,191 @@
+// Package retry implements jittered exponential backoff with Retry-After
+// support and a shared time budget.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retryable is implemented by errors that know whether repeating the
+// request may succeed.
+type Retryable interface {
+	Retryable() bool
+}
+
+// RetryAfterer is implemented by errors carrying a server-requested delay.
+type RetryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+// IsTemporary is the default classifier: errors implementing Retryable
+// decide for themselves, transport failures (*url.Error) are retried and
+// everything else (bad JSON, encoding errors) is not.
+func IsTemporary(err error) bool {
+	var r Retryable
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// TemporaryStatus reports whether an HTTP status is worth retrying.
+func TemporaryStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// ParseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date. It returns 0 when the header is absent or malformed.
+func ParseRetryAfter(h string, now time.Time) time.Duration {
+	h = strings.TrimSpace(h)
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// Budget caps the total time spent waiting between retries. One budget is
+// shared by every policy and reset at the start of each heartbeat.
+type Budget struct {
+	mu        sync.Mutex
+	remaining time.Duration
+	unlimited bool
+}
+
+// NewBudget returns a budget of d; d <= 0 means unlimited.
+func NewBudget(d time.Duration) *Budget {
+	b := &Budget{}
+	b.Reset(d)
+	return b
+}
+
+func (b *Budget) Reset(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remaining = d
+	b.unlimited = d <= 0
+}
+
+// take reserves d from the budget, reporting false if it would overrun.
+func (b *Budget) take(d time.Duration) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.unlimited {
+		return true
+	}
+	if d > b.remaining {
+		return false
+	}
+	b.remaining -= d
+	return true
+}
+
+type Policy struct {
+	MaxAttempts int           // total attempts including the first; <= 1 disables retries
+	BaseDelay   time.Duration // delay before the first retry, doubled each time
+	MaxDelay    time.Duration // upper bound for the backoff delay (not for Retry-After)
+	Budget      *Budget       // optional shared cap on total waiting time
+
+	// ShouldRetry classifies errors; nil means IsTemporary.
+	ShouldRetry func(error) bool
+	// OnRetry is called before each wait, e.g. for logging.
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// Do runs fn until it succeeds, fails permanently, runs out of attempts,
+// the next wait would exceed the budget or ctx is done. The last error is
+// returned; after cancellation it wraps ctx.Err().
+func (p Policy) Do(ctx context.Context, fn func() error) error {
+	shouldRetry := p.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = IsTemporary
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return canceled(ctx, err)
+		}
+		if attempt >= p.MaxAttempts || !shouldRetry(err) {
+			return err
+		}
+		delay := p.backoff(attempt)
+		var ra RetryAfterer
+		if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+			delay = ra.RetryAfter()
+		}
+		if !p.Budget.take(delay) {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, delay, err)
+		}
+		if err := Sleep(ctx, delay); err != nil {
+			return canceled(ctx, err)
+		}
+	}
+}
+
+// canceled returns err if it already says why ctx ended, and otherwise
+// wraps ctx.Err() so errors.Is(err, context.Canceled) works for callers.
+func canceled(ctx context.Context, err error) error {
+	if errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first, and
+// returns ctx.Err() in the latter case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns an "equal jitter" delay: half of the exponential step
+// plus a random share of the other half.
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
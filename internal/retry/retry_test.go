@@ -0,0 +1,172 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// tempErr is a retryable error that may ask for a delay.
+type tempErr struct{ after time.Duration }
+
+func (tempErr) Error() string               { return "temporary" }
+func (tempErr) Retryable() bool             { return true }
+func (e tempErr) RetryAfter() time.Duration { return e.after }
+
+// permErr refuses to be retried.
+type permErr struct{}
+
+func (permErr) Error() string   { return "permanent" }
+func (permErr) Retryable() bool { return false }
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for header, want := range map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		" 5 ":                           5 * time.Second,
+		"0":                             0,
+		"-3":                            0,
+		"Sun, 01 Mar 2026 12:00:30 GMT": 30 * time.Second,
+		"Sun, 01 Mar 2026 11:59:00 GMT": 0, // already past
+		"soon":                          0,
+	} {
+		if got := ParseRetryAfter(header, now); got != want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestBackoffStaysInItsStep(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	// Equal jitter: the delay for step d is within [d/2, d].
+	steps := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i := 0; i < 200; i++ {
+		for n, d := range steps {
+			if got := p.backoff(n + 1); got < d/2 || got > d {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", n+1, got, d/2, d)
+			}
+		}
+	}
+	// A shift past 63 bits must not wrap around to a tiny or negative delay.
+	if got := p.backoff(80); got < 500*time.Millisecond {
+		t.Errorf("backoff(80) = %v, want the capped delay", got)
+	}
+	if got := (Policy{}).backoff(3); got != 0 {
+		t.Errorf("backoff without delays = %v, want 0", got)
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	if !IsTemporary(tempErr{}) || IsTemporary(permErr{}) {
+		t.Error("errors implementing Retryable should decide for themselves")
+	}
+	if !IsTemporary(&url.Error{Op: "Get", URL: "http://x", Err: errors.New("connection refused")}) {
+		t.Error("transport errors should be retried")
+	}
+	if IsTemporary(errors.New("invalid character '<'")) {
+		t.Error("plain errors should not be retried")
+	}
+}
+
+// attempts returns a function failing with errs in turn, then succeeding,
+// and a pointer to the number of calls.
+func attempts(errs ...error) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	fn, calls := attempts(tempErr{}, tempErr{})
+	var waits []int
+	p := Policy{MaxAttempts: 5, BaseDelay: time.Millisecond, OnRetry: func(n int, _ time.Duration, _ error) { waits = append(waits, n) }}
+	if err := p.Do(context.Background(), fn); err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 3 || len(waits) != 2 || waits[1] != 2 {
+		t.Errorf("calls = %d, OnRetry attempts = %v", *calls, waits)
+	}
+}
+
+func TestDoStops(t *testing.T) {
+	fn, calls := attempts(tempErr{}, tempErr{}, tempErr{})
+	if err := (Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}).Do(context.Background(), fn); err == nil || *calls != 2 {
+		t.Errorf("MaxAttempts 2: calls = %d, err = %v", *calls, err)
+	}
+
+	fn, calls = attempts(permErr{})
+	if err := (Policy{MaxAttempts: 5}).Do(context.Background(), fn); !errors.Is(err, permErr{}) || *calls != 1 {
+		t.Errorf("permanent error: calls = %d, err = %v", *calls, err)
+	}
+
+	fn, calls = attempts(tempErr{})
+	if err := (Policy{MaxAttempts: 1}).Do(context.Background(), fn); err == nil || *calls != 1 {
+		t.Errorf("MaxAttempts 1: calls = %d, err = %v", *calls, err)
+	}
+}
+
+func TestDoHonoursRetryAfter(t *testing.T) {
+	fn, _ := attempts(tempErr{after: 3 * time.Millisecond})
+	var delay time.Duration
+	// Retry-After wins over the backoff, even above MaxDelay.
+	p := Policy{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: time.Millisecond,
+		OnRetry: func(_ int, d time.Duration, _ error) { delay = d }}
+	if err := p.Do(context.Background(), fn); err != nil {
+		t.Fatal(err)
+	}
+	if delay != 3*time.Millisecond {
+		t.Errorf("delay = %v, want the server's 3ms", delay)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(5 * time.Millisecond)
+	p := Policy{MaxAttempts: 10, Budget: b}
+
+	// Two 2ms waits fit, the third would overrun: the error is returned.
+	fn, calls := attempts(tempErr{2 * time.Millisecond}, tempErr{2 * time.Millisecond}, tempErr{2 * time.Millisecond})
+	if err := p.Do(context.Background(), fn); err == nil || *calls != 3 {
+		t.Errorf("calls = %d, err = %v; want to give up on the third failure", *calls, err)
+	}
+	// The budget is shared: what is left (1ms) is too little for another 2ms.
+	fn, calls = attempts(tempErr{2 * time.Millisecond})
+	if err := p.Do(context.Background(), fn); err == nil || *calls != 1 {
+		t.Errorf("second policy run: calls = %d, err = %v", *calls, err)
+	}
+	b.Reset(0) // unlimited
+	fn, calls = attempts(tempErr{2 * time.Millisecond})
+	if err := p.Do(context.Background(), fn); err != nil || *calls != 2 {
+		t.Errorf("after Reset(0): calls = %d, err = %v", *calls, err)
+	}
+}
+
+func TestDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fn, calls := attempts(tempErr{}, tempErr{}, tempErr{})
+	p := Policy{MaxAttempts: 3, BaseDelay: time.Hour}
+	p.OnRetry = func(int, time.Duration, error) { cancel() }
+	err := p.Do(ctx, fn)
+	if !errors.Is(err, context.Canceled) || *calls != 1 {
+		t.Errorf("calls = %d, err = %v; want to stop waiting with context.Canceled", *calls, err)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := Sleep(ctx, time.Millisecond); err != nil {
+		t.Errorf("Sleep = %v", err)
+	}
+	cancel()
+	start := time.Now()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+		t.Errorf("Sleep on a done context = %v after %v", err, time.Since(start))
+	}
+}
@@ -0,0 +1,162 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@yearly",
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := parseCron(expr); err == nil {
+				t.Errorf("parseCron(%q) succeeded, want an error", expr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		expr string
+		from string
+		want []string // successive runs
+	}{
+		{"*/10 * * * *", "2026-01-05 10:03", []string{"2026-01-05 10:10", "2026-01-05 10:20"}},
+		{"0-29/10 * * * *", "2026-01-05 10:25", []string{"2026-01-05 11:00", "2026-01-05 11:10", "2026-01-05 11:20", "2026-01-05 12:00"}},
+		{"15/20 * * * *", "2026-01-05 10:00", []string{"2026-01-05 10:15", "2026-01-05 10:35", "2026-01-05 10:55", "2026-01-05 11:15"}},
+		{"0 9-17/4 * * *", "2026-01-05 10:00", []string{"2026-01-05 13:00", "2026-01-05 17:00", "2026-01-06 09:00"}},
+		{"0 9,18 * * *", "2026-01-05 09:00", []string{"2026-01-05 18:00", "2026-01-06 09:00"}},
+		{"30 8 * * 1-5", "2026-01-09 09:00", []string{"2026-01-12 08:30"}}, // Friday -> Monday
+		{"0 0 * * 7", "2026-01-05 00:00", []string{"2026-01-11 00:00"}},    // 7 is Sunday
+		{"0 0 1 * 1", "2026-01-02 00:00", []string{"2026-01-05 00:00", "2026-01-12 00:00"}},
+		{"0 0 31 * *", "2026-01-31 00:00", []string{"2026-03-31 00:00"}},
+		{"0 0 29 2 *", "2026-01-01 00:00", []string{"2028-02-29 00:00"}},
+		{"@hourly", "2026-01-05 10:00", []string{"2026-01-05 11:00"}},
+		{"@weekly", "2026-01-05 10:00", []string{"2026-01-11 00:00"}},
+		{"0 12 * * *", "2026-12-31 12:00", []string{"2027-01-01 12:00"}},
+		{"* * * * *", "2026-01-05 10:00", []string{"2026-01-05 10:01"}}, // strictly after
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+" from "+tt.from, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at := utc(tt.from)
+			for i, want := range tt.want {
+				at = c.Next(at)
+				if !at.Equal(utc(want)) {
+					t.Fatalf("run %d = %s, want %s", i+1, at.Format("2006-01-02 15:04 Mon"), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	c, err := parseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %v, want zero time", got)
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	load := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("no time zone data: %v", err)
+		}
+		return loc
+	}
+	ny := load("America/New_York")       // 2026-03-08 02:00 -> 03:00, 2026-11-01 02:00 -> 01:00
+	santiago := load("America/Santiago") // 2026-09-06 00:00 -> 01:00
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []string // RFC 3339 with offset
+	}{
+		{
+			name: "skipped hour is skipped",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 3, 7, 12, 0, 0, 0, ny),
+			want: []string{"2026-03-09T02:30:00-04:00"},
+		},
+		{
+			name: "hourly across the gap",
+			expr: "0 * * * *",
+			from: time.Date(2026, 3, 8, 0, 30, 0, 0, ny),
+			want: []string{"2026-03-08T01:00:00-05:00", "2026-03-08T03:00:00-04:00", "2026-03-08T04:00:00-04:00"},
+		},
+		{
+			name: "steps across the gap",
+			expr: "*/30 * * * *",
+			from: time.Date(2026, 3, 8, 1, 10, 0, 0, ny),
+			want: []string{"2026-03-08T01:30:00-05:00", "2026-03-08T03:00:00-04:00"},
+		},
+		{
+			name: "repeated hour runs once",
+			expr: "30 1 * * *",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, ny),
+			want: []string{"2026-11-01T01:30:00-04:00", "2026-11-02T01:30:00-05:00"},
+		},
+		{
+			name: "hourly keeps the repeated hour",
+			expr: "0 * * * *",
+			from: time.Date(2026, 11, 1, 0, 30, 0, 0, ny),
+			want: []string{"2026-11-01T01:00:00-04:00", "2026-11-01T01:00:00-05:00", "2026-11-01T02:00:00-05:00"},
+		},
+		{
+			name: "missing midnight",
+			expr: "0 0 * * *",
+			from: time.Date(2026, 9, 5, 12, 0, 0, 0, santiago),
+			want: []string{"2026-09-07T00:00:00-03:00"},
+		},
+		{
+			name: "daily run after missing midnight",
+			expr: "0 9 * * *",
+			from: time.Date(2026, 9, 5, 12, 0, 0, 0, santiago),
+			want: []string{"2026-09-06T09:00:00-03:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at := tt.from
+			for i, want := range tt.want {
+				at = c.Next(at)
+				if got := at.Format(time.RFC3339); got != want {
+					t.Fatalf("run %d = %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+// Package schedule decides when each bot action runs: a cron expression or
+// a fixed interval per action, random jitter, and quiet hours in a chosen
+// time zone.
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones work on hosts without a zoneinfo database
+)
+
+// Spec yields the run after a given time.
+type Spec interface {
+	Next(after time.Time) time.Time
+	String() string
+}
+
+type everySpec time.Duration
+
+func (e everySpec) Next(after time.Time) time.Time { return after.Add(time.Duration(e)) }
+func (e everySpec) String() string                 { return "every " + time.Duration(e).String() }
+
+// Parse returns a cron spec when expr is set, otherwise a fixed interval.
+func Parse(expr string, interval time.Duration) (Spec, error) {
+	if strings.TrimSpace(expr) != "" {
+		return parseCron(expr)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be > 0 (got %v)", interval)
+	}
+	return everySpec(interval), nil
+}
+
+// Window is a daily time range such as 23:00-07:00; it may wrap midnight.
+type Window struct {
+	start, end int // minutes since midnight
+}
+
+// ParseWindow reads "HH:MM-HH:MM". An empty string or "off" means no window.
+func ParseWindow(s string) (*Window, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" {
+		return nil, nil
+	}
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(a)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	end, err := parseClock(b)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("quiet hours %q: start and end are the same", s)
+	}
+	return &Window{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+func (w *Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
+
+// Contains reports whether t (in its own location) is inside the window.
+func (w *Window) Contains(t time.Time) bool {
+	if w == nil {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// After returns t, or the end of the window if t falls inside it.
+func (w *Window) After(t time.Time) time.Time {
+	if !w.Contains(t) {
+		return t
+	}
+	end := time.Date(t.Year(), t.Month(), t.Day(), w.end/60, w.end%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// Action is the schedule of one named action.
+type Action struct {
+	Name   string
+	Spec   Spec
+	Jitter time.Duration // up to this much is added to each run
+	Quiet  *Window       // optional; runs are pushed past it
+}
+
+// base is the unjittered next run: right away when the action never ran
+// (or, for cron, its next slot), otherwise the spec's next time after the
+// last run, but never in the past.
+func (a Action) base(last, now time.Time) time.Time {
+	var next time.Time
+	switch {
+	case !last.IsZero():
+		next = a.Spec.Next(last)
+	case isCron(a.Spec):
+		next = a.Spec.Next(now)
+	default:
+		next = now
+	}
+	if next.Before(now) {
+		next = now
+	}
+	return next
+}
+
+// outsideQuiet moves t out of the quiet hours: cron runs skip to the next
+// slot after them, interval runs wait until they end.
+func (a Action) outsideQuiet(t time.Time) time.Time {
+	if !isCron(a.Spec) {
+		return a.Quiet.After(t)
+	}
+	for i := 0; i < 100000 && a.Quiet.Contains(t); i++ {
+		t = a.Spec.Next(t)
+	}
+	return t
+}
+
+func isCron(s Spec) bool {
+	_, ok := s.(*cronSpec)
+	return ok
+}
+
+// Due reports whether the action may run at now given its last run,
+// ignoring jitter and quiet hours. Manual commands use it to respect the
+// configured interval.
+func (a Action) Due(last, now time.Time) bool {
+	return last.IsZero() || !a.Spec.Next(last).After(now)
+}
+
+// Scheduler plans the next run of every action. All times are handled in
+// Location, so cron fields and quiet hours mean local wall-clock time there.
+type Scheduler struct {
+	Location *time.Location
+	actions  []Action
+	last     map[string]time.Time
+	planned  map[string]time.Time
+	rand     *rand.Rand
+}
+
+func New(loc *time.Location, actions []Action) *Scheduler {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Scheduler{
+		Location: loc,
+		actions:  actions,
+		last:     map[string]time.Time{},
+		planned:  map[string]time.Time{},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Actions returns the actions in configured order.
+func (s *Scheduler) Actions() []Action { return s.actions }
+
+// SetLast seeds the last run of an action, e.g. from persisted state.
+func (s *Scheduler) SetLast(name string, t time.Time) {
+	s.last[name] = t
+	delete(s.planned, name)
+}
+
+// Last returns the last run of an action, zero if it never ran.
+func (s *Scheduler) Last(name string) time.Time { return s.last[name] }
+
+// plan fixes the next run of a, jitter included, so that repeated calls
+// agree until the action runs.
+func (s *Scheduler) plan(a Action, now time.Time) time.Time {
+	if t, ok := s.planned[a.Name]; ok {
+		return t
+	}
+	t := a.base(s.last[a.Name].In(s.Location), now.In(s.Location))
+	if t.IsZero() {
+		return t // cron without a future match
+	}
+	if a.Jitter > 0 {
+		t = t.Add(time.Duration(s.rand.Int63n(int64(a.Jitter) + 1)))
+	}
+	t = a.outsideQuiet(t)
+	s.planned[a.Name] = t
+	return t
+}
+
+// Next returns the earliest planned run and the actions planned for it.
+// ok is false when no action will ever run again.
+func (s *Scheduler) Next(now time.Time) (at time.Time, names []string, ok bool) {
+	for _, a := range s.actions {
+		t := s.plan(a, now)
+		if t.IsZero() {
+			continue
+		}
+		if !ok || t.Before(at) {
+			at, ok = t, true
+		}
+	}
+	for _, a := range s.actions {
+		if ok && s.planned[a.Name].Equal(at) {
+			names = append(names, a.Name)
+		}
+	}
+	return at, names, ok
+}
+
+// Due returns the actions whose planned run is not after now, in
+// configured order.
+func (s *Scheduler) Due(now time.Time) []string {
+	var names []string
+	for _, a := range s.actions {
+		if t := s.plan(a, now); !t.IsZero() && !t.After(now) {
+			names = append(names, a.Name)
+		}
+	}
+	return names
+}
+
+// Done records that an action ran at t and plans its next run afresh.
+func (s *Scheduler) Done(name string, t time.Time) {
+	s.SetLast(name, t)
+}
+
+// Retry seeds the last run of an action that ran without effect and plans
+// its next run no earlier than notBefore.
+func (s *Scheduler) Retry(name string, last, notBefore time.Time) {
+	s.SetLast(name, last)
+	for _, a := range s.actions {
+		if a.Name == name {
+			s.plan(a, notBefore)
+		}
+	}
+}
+
+// Run is one upcoming run shown by Upcoming.
+type Run struct {
+	Action string
+	At     time.Time // without jitter; the actual run is up to Jitter later
+	Jitter time.Duration
+}
+
+// Upcoming lists the next n runs of every action after now, without
+// jitter, sorted by action order. Quiet hours are applied.
+func (s *Scheduler) Upcoming(now time.Time, n int) []Run {
+	var runs []Run
+	for _, a := range s.actions {
+		last := s.last[a.Name].In(s.Location)
+		t := a.outsideQuiet(a.base(last, now.In(s.Location)))
+		for i := 0; i < n && !t.IsZero(); i++ {
+			runs = append(runs, Run{Action: a.Name, At: t, Jitter: a.Jitter})
+			t = a.outsideQuiet(a.Spec.Next(t))
+		}
+	}
+	return runs
+}
@@ -0,0 +1,196 @@
+package schedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string // String() of the window; "" = none
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "off", want: ""},
+		{in: "23:00-07:00", want: "23:00-07:00"},
+		{in: " 9:5 - 17:30 ", want: "09:05-17:30"},
+		{in: "23:00", wantErr: true},
+		{in: "24:00-07:00", wantErr: true},
+		{in: "10:60-11:00", wantErr: true},
+		{in: "08:00-08:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			w, err := ParseWindow(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindow(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := ""
+			if w != nil {
+				got = w.String()
+			}
+			if got != tt.want {
+				t.Errorf("ParseWindow(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindow(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04", "2026-01-05 "+hhmm)
+		return t
+	}
+	tests := []struct {
+		window   string
+		t        string
+		contains bool
+		after    string // what After returns
+		nextDay  bool   // after is on the following day
+	}{
+		{"23:00-07:00", "23:30", true, "07:00", true},
+		{"23:00-07:00", "03:00", true, "07:00", false},
+		{"23:00-07:00", "07:00", false, "07:00", false},
+		{"23:00-07:00", "12:00", false, "12:00", false},
+		{"12:00-13:00", "12:00", true, "13:00", false},
+		{"12:00-13:00", "13:00", false, "13:00", false},
+		{"12:00-13:00", "11:59", false, "11:59", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.window+" at "+tt.t, func(t *testing.T) {
+			w, err := ParseWindow(tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Contains(at(tt.t)); got != tt.contains {
+				t.Errorf("Contains = %v, want %v", got, tt.contains)
+			}
+			want := at(tt.after)
+			if tt.nextDay {
+				want = want.AddDate(0, 0, 1)
+			}
+			if got := w.After(at(tt.t)); !got.Equal(want) {
+				t.Errorf("After = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSchedulerNext(t *testing.T) {
+	now := time.Date(2026, 1, 5, 10, 17, 0, 0, time.UTC)
+	quiet, _ := ParseWindow("22:00-08:00")
+	every := func(d time.Duration) Spec { s, _ := Parse("", d); return s }
+	cron := func(expr string) Spec { s, _ := Parse(expr, 0); return s }
+	tests := []struct {
+		name      string
+		actions   []Action
+		last      map[string]time.Time
+		wantAt    time.Time
+		wantNames []string
+	}{
+		{
+			name:      "never ran interval runs now",
+			actions:   []Action{{Name: "reply", Spec: every(time.Hour)}},
+			wantAt:    now,
+			wantNames: []string{"reply"},
+		},
+		{
+			name:      "never ran cron waits for its slot",
+			actions:   []Action{{Name: "engage", Spec: cron("0 * * * *")}},
+			wantAt:    time.Date(2026, 1, 5, 11, 0, 0, 0, time.UTC),
+			wantNames: []string{"engage"},
+		},
+		{
+			name:      "interval after last run",
+			actions:   []Action{{Name: "post", Spec: every(4 * time.Hour)}},
+			last:      map[string]time.Time{"post": now.Add(-time.Hour)},
+			wantAt:    now.Add(3 * time.Hour),
+			wantNames: []string{"post"},
+		},
+		{
+			name:      "overdue runs now",
+			actions:   []Action{{Name: "post", Spec: every(time.Hour)}},
+			last:      map[string]time.Time{"post": now.Add(-5 * time.Hour)},
+			wantAt:    now,
+			wantNames: []string{"post"},
+		},
+		{
+			name: "earliest wins, ties listed in order",
+			actions: []Action{
+				{Name: "a", Spec: cron("30 * * * *")},
+				{Name: "b", Spec: cron("0,30 * * * *")},
+				{Name: "c", Spec: cron("45 * * * *")},
+			},
+			wantAt:    time.Date(2026, 1, 5, 10, 30, 0, 0, time.UTC),
+			wantNames: []string{"a", "b"},
+		},
+		{
+			name:      "interval waits out quiet hours",
+			actions:   []Action{{Name: "post", Spec: every(12 * time.Hour), Quiet: quiet}},
+			last:      map[string]time.Time{"post": now},
+			wantAt:    time.Date(2026, 1, 6, 8, 0, 0, 0, time.UTC),
+			wantNames: []string{"post"},
+		},
+		{
+			name:      "cron skips to its first slot after quiet hours",
+			actions:   []Action{{Name: "engage", Spec: cron("15 */3 * * *"), Quiet: quiet}},
+			last:      map[string]time.Time{"engage": time.Date(2026, 1, 5, 21, 15, 0, 0, time.UTC)},
+			wantAt:    time.Date(2026, 1, 6, 9, 15, 0, 0, time.UTC),
+			wantNames: []string{"engage"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(time.UTC, tt.actions)
+			for name, last := range tt.last {
+				s.SetLast(name, last)
+			}
+			at, names, ok := s.Next(now)
+			if !ok {
+				t.Fatal("Next: nothing planned")
+			}
+			if !at.Equal(tt.wantAt) || !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Next = %v %v, want %v %v", at, names, tt.wantAt, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestSchedulerJitterIsStable(t *testing.T) {
+	now := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	spec, _ := Parse("", time.Hour)
+	s := New(time.UTC, []Action{{Name: "reply", Spec: spec, Jitter: 10 * time.Minute}})
+	s.SetLast("reply", now)
+	first, _, _ := s.Next(now)
+	if first.Before(now.Add(time.Hour)) || first.After(now.Add(70*time.Minute)) {
+		t.Fatalf("Next = %v, want within 10m jitter after %v", first, now.Add(time.Hour))
+	}
+	for i := 0; i < 10; i++ {
+		if again, _, _ := s.Next(now.Add(time.Duration(i) * time.Minute)); !again.Equal(first) {
+			t.Fatalf("planned run moved from %v to %v", first, again)
+		}
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	spec, _ := Parse("", 6*time.Hour)
+	s := New(time.UTC, []Action{{Name: "post", Spec: spec}})
+	// Last posted long ago, but the attempt just now didn't post.
+	s.Retry("post", start.Add(-48*time.Hour), start.Add(time.Hour))
+	if due := s.Due(start.Add(30 * time.Minute)); len(due) != 0 {
+		t.Errorf("Due before the retry = %v, want none", due)
+	}
+	if due := s.Due(start.Add(time.Hour)); !reflect.DeepEqual(due, []string{"post"}) {
+		t.Errorf("Due at the retry = %v, want [post]", due)
+	}
+	s.Done("post", start.Add(time.Hour))
+	if at, _, _ := s.Next(start.Add(time.Hour)); !at.Equal(start.Add(7 * time.Hour)) {
+		t.Errorf("Next after Done = %v, want %v", at, start.Add(7*time.Hour))
+	}
+}